@@ -0,0 +1,154 @@
+package problem
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"slices"
+	"strconv"
+	"unicode"
+)
+
+// encodeAppendixB encodes the JSON value raw as an XML element named name, following the rules
+// of https://www.rfc-editor.org/rfc/rfc9457.html#name-xml-format
+//
+//   - Arrays are encoded as elements with an <i> child element for every item.
+//   - Objects are encoded as elements with a child element for every member, sorted by name.
+//   - Strings, numbers and booleans are encoded as text.
+//   - null is encoded as an empty element.
+//
+// An error is returned if name, or the name of a member of an object, is not a valid XML
+// element name, since the document would be malformed.
+func encodeAppendixB(e *xml.Encoder, name string, raw json.RawMessage) error {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return err
+	}
+
+	return encodeAppendixBValue(e, xml.Name{Local: name}, v)
+}
+
+func encodeAppendixBValue(e *xml.Encoder, name xml.Name, v any) error {
+	if !isXMLName(name.Local) {
+		return fmt.Errorf("problem: member %q cannot be encoded in XML, it is not a valid element name", name.Local)
+	}
+
+	start := xml.StartElement{Name: name}
+
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+
+	var err error
+
+	switch v := v.(type) {
+	case nil:
+	case string:
+		err = e.EncodeToken(xml.CharData(v))
+	case json.Number:
+		err = e.EncodeToken(xml.CharData(v.String()))
+	case bool:
+		err = e.EncodeToken(xml.CharData(strconv.FormatBool(v)))
+	case []any:
+		for _, item := range v {
+			if err = encodeAppendixBValue(e, xml.Name{Local: "i"}, item); err != nil {
+				break
+			}
+		}
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		slices.Sort(keys)
+
+		for _, k := range keys {
+			if err = encodeAppendixBValue(e, xml.Name{Local: k}, v[k]); err != nil {
+				break
+			}
+		}
+	default:
+		err = fmt.Errorf("problem: unexpected JSON value of type %T", v)
+	}
+	if err != nil {
+		return err
+	}
+
+	return e.EncodeToken(start.End())
+}
+
+// isXMLName reports whether name is a valid XML element name without namespace prefix, as
+// defined by https://www.w3.org/TR/xml/#NT-Name, approximated with the Unicode letter and digit
+// categories.
+func isXMLName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, r := range name {
+		switch {
+		case r == '_' || unicode.IsLetter(r):
+		case i > 0 && (r == '-' || r == '.' || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r)):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// decodeAppendixB decodes the content of the element whose start was just read from d, reversing
+// the rules of [encodeAppendixB]. Since XML has no value types, the type information lost on
+// encoding cannot be recovered:
+//
+//   - Elements with child elements, all of them named <i>, are decoded as []any.
+//   - Elements with other child elements are decoded as map[string]any, the text between the
+//     child elements is ignored.
+//   - Other elements are decoded as their text, numbers, booleans and null included.
+func decodeAppendixB(d *xml.Decoder) (any, error) {
+	var (
+		text   []byte
+		names  []string
+		values []any
+	)
+
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			v, err := decodeAppendixB(d)
+			if err != nil {
+				return nil, err
+			}
+			names = append(names, t.Name.Local)
+			values = append(values, v)
+
+		case xml.CharData:
+			text = append(text, t...)
+
+		case xml.EndElement:
+			if len(names) == 0 {
+				return string(text), nil
+			}
+
+			isArray := !slices.ContainsFunc(names, func(name string) bool {
+				return name != "i"
+			})
+			if isArray {
+				return values, nil
+			}
+
+			object := make(map[string]any, len(names))
+			for i, name := range names {
+				object[name] = values[i]
+			}
+			return object, nil
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package problem
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+)
+
+// Type describes a problem type, as defined in
+// https://www.rfc-editor.org/rfc/rfc9457.html#name-defining-new-problem-types
+//
+// Types are declared once in a [Catalog], and problems are created from them with [Type.New],
+// guaranteeing that the "title" member for a given "type" never changes between occurrences.
+type Type struct {
+	// Type URI, used as the "type" member. Required.
+	URI string
+
+	// Short, human-readable summary of the problem type, used as the "title" member. If empty,
+	// the status text of Status is used.
+	Title string
+
+	// Default status code of the problems of this type. Required.
+	Status int
+
+	// Human-readable explanation of the problem type, intended for documentation purposes,
+	// it is never included in problem occurrences.
+	Description string
+}
+
+// New returns a *[RegisteredProblem] of type t, with the status code and title of t, and the
+// given details. If t has no title, the status text of its status code is used.
+func (t Type) New(details string) *RegisteredProblem {
+	title := t.Title
+	if title == "" {
+		title = http.StatusText(t.Status)
+	}
+	return &RegisteredProblem{
+		Type:   t.URI,
+		Status: t.Status,
+		Title:  title,
+		Detail: details,
+		stack:  captureStack(1),
+	}
+}
+
+// Catalog is a registry of problem types, indexed by type URI.
+//
+// The zero value is an empty Catalog ready to use, and it is safe for concurrent use.
+type Catalog struct {
+	mu    sync.RWMutex
+	types map[string]Type
+
+	// Type URIs in order of registration
+	uris []string
+}
+
+// Register adds t to the catalog, defaulting its title to the status text of its status code.
+//
+// An error [ErrInvalidType] is returned if t has no URI, the URI cannot be parsed, or the status
+// code is not a valid HTTP status code. An error [ErrDuplicateType] is returned if a type with
+// the same URI was already registered.
+func (c *Catalog) Register(t Type) (Type, error) {
+	if t.URI == "" {
+		return Type{}, fmt.Errorf("%w: missing URI", ErrInvalidType)
+	}
+	if _, err := url.Parse(t.URI); err != nil {
+		return Type{}, fmt.Errorf("%w: %w", ErrInvalidType, err)
+	}
+	if t.Status < 100 || t.Status > 599 {
+		return Type{}, fmt.Errorf("%w: invalid status code %d", ErrInvalidType, t.Status)
+	}
+	if t.Title == "" {
+		t.Title = http.StatusText(t.Status)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.types[t.URI]; ok {
+		return Type{}, fmt.Errorf("%w: '%s'", ErrDuplicateType, t.URI)
+	}
+
+	if c.types == nil {
+		c.types = map[string]Type{}
+	}
+	c.types[t.URI] = t
+	c.uris = append(c.uris, t.URI)
+
+	return t, nil
+}
+
+// MustRegister is like [Catalog.Register] but panics if t cannot be registered. It simplifies
+// the declaration of types in package-level variables:
+//
+//	var OutOfCredit = catalog.MustRegister(problem.Type{
+//	    URI:    "https://example.com/probs/out-of-credit",
+//	    Title:  "You do not have enough credit.",
+//	    Status: http.StatusForbidden,
+//	})
+func (c *Catalog) MustRegister(t Type) Type {
+	t, err := c.Register(t)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// Lookup returns the type registered with the given URI.
+func (c *Catalog) Lookup(uri string) (Type, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	t, ok := c.types[uri]
+	return t, ok
+}
+
+// Match returns the registered type of p, looked up by its "type" member. It is useful for
+// recognising known types in problems returned by [ParseResponse] and [ParseResponseCustom].
+func (c *Catalog) Match(p Problem) (Type, bool) {
+	return c.Lookup(p.GetType())
+}
+
+// Types returns all the registered types, in order of registration.
+func (c *Catalog) Types() []Type {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	types := make([]Type, len(c.uris))
+	for i, uri := range c.uris {
+		types[i] = c.types[uri]
+	}
+	return types
+}
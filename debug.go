@@ -0,0 +1,115 @@
+package problem
+
+import (
+	"encoding/json"
+	"fmt"
+	"runtime"
+	"sync/atomic"
+)
+
+// Maximum number of stack frames captured by the constructors.
+const maxStackDepth = 32
+
+var captureStacks atomic.Bool
+
+// SetCaptureStacks enables or disables capturing the stack of the goroutine creating problems,
+// with [NewRegistered], [NewValidation] and [Type.New], or the problems returned by
+// [Mapper.FromError] and [Recover]. The stack is served in the "stack" extension member when
+// [WithDebug] is used.
+//
+// The setting is process-wide, it affects every problem created afterwards by any goroutine.
+//
+// It is disabled by default, since capturing stacks has a cost. It is intended to be enabled in
+// debug builds, e.g. from a file with a build constraint:
+//
+//	//go:build debug
+//
+//	func init() {
+//	    problem.SetCaptureStacks(true)
+//	}
+func SetCaptureStacks(enabled bool) {
+	captureStacks.Store(enabled)
+}
+
+// stackTrace holds the program counters of a captured stack. It is referenced by pointer, so
+// problems embedding it stay comparable.
+type stackTrace struct {
+	pc []uintptr
+}
+
+// captureStack returns the stack of the caller of the constructors calling it, skip being the
+// number of constructor frames, or nil if stack capturing is disabled.
+func captureStack(skip int) *stackTrace {
+	if !captureStacks.Load() {
+		return nil
+	}
+
+	pc := make([]uintptr, maxStackDepth)
+	// Skip runtime.Callers, captureStack and the constructors
+	n := runtime.Callers(2+skip, pc)
+	return &stackTrace{pc: pc[:n]}
+}
+
+// stackFrames returns the captured stack frames of p, formatted as 'function file:line'.
+func stackFrames(p Problem) []string {
+	s, ok := p.(interface{ capturedStack() *stackTrace })
+	if !ok || s.capturedStack() == nil {
+		return nil
+	}
+
+	var frames []string
+
+	it := runtime.CallersFrames(s.capturedStack().pc)
+	for {
+		frame, more := it.Next()
+		frames = append(frames, fmt.Sprintf("%s %s:%d", frame.Function, frame.File, frame.Line))
+		if !more {
+			break
+		}
+	}
+
+	return frames
+}
+
+// causeChain returns the messages of the errors wrapped by p, depth-first.
+func causeChain(p Problem) []string {
+	var causes []string
+
+	var walk func(err error)
+	walk = func(err error) {
+		switch u := err.(type) {
+		case interface{ Unwrap() error }:
+			if cause := u.Unwrap(); cause != nil {
+				causes = append(causes, cause.Error())
+				walk(cause)
+			}
+		case interface{ Unwrap() []error }:
+			for _, cause := range u.Unwrap() {
+				if cause != nil {
+					causes = append(causes, cause.Error())
+					walk(cause)
+				}
+			}
+		}
+	}
+	walk(p)
+
+	return causes
+}
+
+// debugMembers returns the "stack" and "cause" extension members of p, see [WithDebug].
+func debugMembers(p Problem) []member {
+	var members []member
+
+	if frames := stackFrames(p); len(frames) > 0 {
+		b, _ := json.Marshal(frames)
+		members = append(members, member{name: "stack", value: b})
+	}
+
+	if causes := causeChain(p); len(causes) > 0 {
+		b, _ := json.Marshal(causes)
+		members = append(members, member{name: "cause", value: b})
+	}
+
+	return members
+}
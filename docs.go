@@ -0,0 +1,152 @@
+package problem
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+var docsTemplate = template.Must(template.New("docs").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<dl>
+<dt>Type</dt>
+<dd><code>{{.Type}}</code></dd>
+<dt>Status</dt>
+<dd>{{.Status}} {{.StatusText}}</dd>
+</dl>
+{{with .Description}}<p>{{.}}</p>
+{{end}}<h2>Example</h2>
+<pre><code>{{.ExampleJSON}}</code></pre>
+<pre><code>{{.ExampleXML}}</code></pre>
+</body>
+</html>
+`))
+
+// typeDescription is the machine-readable documentation of a [Type].
+type typeDescription struct {
+	Type        string          `json:"type"`
+	Title       string          `json:"title"`
+	Status      int             `json:"status"`
+	Description string          `json:"description,omitempty"`
+	Example     json.RawMessage `json:"example"`
+}
+
+// DocsHandler returns a Handler serving the documentation of the types registered in c, so the
+// type URIs resolve to human-readable documentation as recommended by
+// https://www.rfc-editor.org/rfc/rfc9457.html#section-3.1.1
+//
+// A type is matched when the request path is equal to the path of its type URI, so the handler
+// must be mounted at the base path of the type URIs, without stripping the prefix:
+//
+//	mux.Handle("GET /probs/", catalog.DocsHandler())
+//
+// Documentation is served as an HTML page, or as a JSON description when the client prefers
+// 'application/json' over 'text/html'. Both contain the title, status code and description of
+// the type, and an example problem encoded the same way as [ServeJSON] and [ServeXML] do.
+//
+// Paths not matching any registered type are answered with a 404 Not Found problem.
+func (c *Catalog) DocsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t, ok := c.lookupPath(r.URL.Path)
+		if !ok {
+			Write(w, r, NewRegistered(http.StatusNotFound, "unknown problem type"))
+			return
+		}
+
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			Write(w, r, NewMethodNotAllowed("", http.MethodGet, http.MethodHead))
+			return
+		}
+
+		example := t.New("")
+
+		buf := getBuffer()
+		defer bufferPool.Put(buf)
+
+		h := w.Header()
+		h.Add("Vary", "Accept")
+		h.Set("X-Content-Type-Options", "nosniff")
+
+		if prefersJSON(r) {
+			_ = encodeProblem(buf, example, MediaTypeProblemJSON, &config{})
+			exampleJSON := json.RawMessage(strings.TrimSpace(buf.String()))
+			buf.Reset()
+
+			_ = json.NewEncoder(buf).Encode(typeDescription{
+				Type:        t.URI,
+				Title:       t.Title,
+				Status:      t.Status,
+				Description: t.Description,
+				Example:     exampleJSON,
+			})
+
+			h.Set("Content-Type", mediaTypeJSON)
+		} else {
+			_ = encodeProblem(buf, example, MediaTypeProblemJSON, &config{})
+			exampleJSON := buf.String()
+			buf.Reset()
+
+			_ = encodeProblem(buf, example, MediaTypeProblemXML, &config{})
+			exampleXML := buf.String()
+			buf.Reset()
+
+			_ = docsTemplate.Execute(buf, map[string]any{
+				"Type":        t.URI,
+				"Title":       t.Title,
+				"Status":      t.Status,
+				"StatusText":  http.StatusText(t.Status),
+				"Description": t.Description,
+				"ExampleJSON": exampleJSON,
+				"ExampleXML":  exampleXML,
+			})
+
+			h.Set("Content-Type", "text/html; charset=utf-8")
+		}
+
+		h.Set("Content-Length", strconv.Itoa(buf.Len()))
+		w.WriteHeader(http.StatusOK)
+		if r.Method != http.MethodHead {
+			_, _ = buf.WriteTo(w)
+		}
+	})
+}
+
+// lookupPath returns the registered type whose URI has the given path.
+func (c *Catalog) lookupPath(path string) (Type, bool) {
+	if path == "" {
+		return Type{}, false
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	for _, uri := range c.uris {
+		u, err := url.Parse(uri)
+		if err == nil && u.Path == path {
+			return c.types[uri], true
+		}
+	}
+	return Type{}, false
+}
+
+// prefersJSON reports whether the client accepts 'application/json' with a higher q-value than
+// 'text/html'.
+func prefersJSON(r *http.Request) bool {
+	ranges := parseAccept(strings.Join(r.Header.Values("Accept"), ","))
+
+	jsonQ, _ := quality(ranges, mediaTypeJSON)
+	htmlQ, ok := quality(ranges, "text/html")
+	if !ok {
+		return jsonQ > 0
+	}
+	return jsonQ > htmlQ
+}
@@ -0,0 +1,207 @@
+package problem
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Encoder configures how problems are encoded in JSON and XML, by the serving helpers (see
+// [WithEncoder]) and by [Encoder.Marshal]. The zero value encodes problems as [json.Marshal] and
+// [xml.Marshal] do.
+type Encoder struct {
+	// Omits registered members with empty values: empty strings, null, and a zero "status".
+	OmitEmpty bool
+
+	// Encodes the registered members first, in the order they are defined by RFC 9457, followed
+	// by the extension members sorted by name.
+	Canonical bool
+
+	// If not empty, every nesting level is indented with Indent, and members are encoded on
+	// their own lines.
+	Indent string
+}
+
+// Marshal returns the encoding of p in the format of contentType, which must be
+// 'application/problem+json', 'application/problem+xml', 'application/json' or
+// 'application/xml'. XML documents start with [xml.Header].
+//
+// The bytes are the same the serving helpers send when using [WithEncoder] with enc, except for
+// the trailing newline.
+func (enc Encoder) Marshal(p Problem, contentType string) ([]byte, error) {
+	var buf bytes.Buffer
+
+	switch contentType {
+	case MediaTypeProblemJSON, mediaTypeJSON, MediaTypeProblemXML, mediaTypeXML:
+		if err := encodeProblem(&buf, p, contentType, &config{encoder: enc}); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("%w: got '%s'", ErrInvalidContentType, contentType)
+	}
+
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// Marshal returns the encoding of p in the format of contentType, using the zero [Encoder], see
+// [Encoder.Marshal].
+func Marshal(p Problem, contentType string) ([]byte, error) {
+	return Encoder{}.Marshal(p, contentType)
+}
+
+// encodeJSON writes p into buf in JSON, followed by a newline.
+func (enc Encoder) encodeJSON(buf *bytes.Buffer, p Problem) error {
+	if enc == (Encoder{}) {
+		return json.NewEncoder(buf).Encode(p)
+	}
+
+	members, err := problemMembers(p)
+	if err != nil {
+		return err
+	}
+
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for _, m := range orderMembers(enc, members) {
+		if b.Len() > 1 {
+			b.WriteByte(',')
+		}
+		name, _ := json.Marshal(m.name)
+		b.Write(name)
+		b.WriteByte(':')
+		b.Write(m.value)
+	}
+	b.WriteByte('}')
+
+	if enc.Indent != "" {
+		if err := json.Indent(buf, b.Bytes(), "", enc.Indent); err != nil {
+			return err
+		}
+	} else {
+		buf.Write(b.Bytes())
+	}
+
+	buf.WriteByte('\n')
+	return nil
+}
+
+// encodeXML writes p into buf in XML, without the XML header.
+func (enc Encoder) encodeXML(buf *bytes.Buffer, p Problem) error {
+	if enc == (Encoder{}) {
+		return xml.NewEncoder(buf).Encode(p)
+	}
+
+	b, err := xml.Marshal(p)
+	if err != nil {
+		return err
+	}
+
+	root, children, err := xmlChildren(b)
+	if err != nil {
+		return err
+	}
+
+	e := xml.NewEncoder(buf)
+	e.Indent("", enc.Indent)
+
+	if err := e.EncodeToken(root); err != nil {
+		return err
+	}
+	for _, c := range orderMembers(enc, children) {
+		for _, tok := range c.tokens {
+			if err := e.EncodeToken(tok); err != nil {
+				return err
+			}
+		}
+	}
+	if err := e.EncodeToken(root.End()); err != nil {
+		return err
+	}
+
+	return e.Flush()
+}
+
+// encodedMember is a member of an encoded problem, either in JSON or in XML.
+type encodedMember interface {
+	getName() string
+
+	// See [Encoder.OmitEmpty]
+	isEmpty() bool
+}
+
+// orderMembers returns the members enc encodes, in the order it encodes them.
+func orderMembers[M encodedMember](enc Encoder, members []M) []M {
+	name := M.getName
+
+	if enc.OmitEmpty {
+		members = slices.DeleteFunc(slices.Clone(members), func(m M) bool {
+			return isRegisteredMember(m.getName()) && m.isEmpty()
+		})
+	}
+
+	if enc.Canonical {
+		// Registered members first, in RFC order, then extension members by name
+		rank := func(m M) int {
+			if i := slices.Index(registeredMembers, name(m)); i >= 0 {
+				return i
+			}
+			return len(registeredMembers)
+		}
+		members = slices.Clone(members)
+		slices.SortStableFunc(members, func(a, b M) int {
+			return cmp.Or(cmp.Compare(rank(a), rank(b)), cmp.Compare(name(a), name(b)))
+		})
+	}
+
+	return members
+}
+
+func (m member) getName() string {
+	return m.name
+}
+
+// isEmpty reports whether the value of m is empty, see [Encoder.OmitEmpty].
+func (m member) isEmpty() bool {
+	switch string(m.value) {
+	case `""`, "null":
+		return true
+	case "0":
+		return m.name == "status"
+	}
+	return false
+}
+
+// xmlChild is a child element of the root element of an XML document, see [xmlChildren].
+type xmlChild struct {
+	name   string
+	tokens []xml.Token
+}
+
+func (c xmlChild) getName() string {
+	return c.name
+}
+
+// isEmpty reports whether the value of c is empty, see [Encoder.OmitEmpty].
+func (c xmlChild) isEmpty() bool {
+	var text []byte
+	for _, tok := range c.tokens[1 : len(c.tokens)-1] {
+		switch t := tok.(type) {
+		case xml.StartElement:
+			return false
+		case xml.CharData:
+			text = append(text, t...)
+		}
+	}
+
+	switch strings.TrimSpace(string(text)) {
+	case "":
+		return true
+	case "0":
+		return c.name == "status"
+	}
+	return false
+}
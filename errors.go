@@ -4,4 +4,17 @@ import "errors"
 
 var (
 	ErrInvalidContentType = errors.New("the Content-Type header is not one of 'application/problem+json' nor 'application/problem+xml'")
+
+	ErrInvalidType   = errors.New("invalid problem type")
+	ErrDuplicateType = errors.New("problem type already registered")
+)
+
+// Violations of RFC 9457 reported by [ParseResponseStrict], see [Violation].
+var (
+	ErrMemberType      = errors.New("member value has the wrong type")
+	ErrMissingType     = errors.New("the type member is missing")
+	ErrStatusMismatch  = errors.New("the status member does not match the status code of the response")
+	ErrInvalidURI      = errors.New("member value is not a URI reference")
+	ErrXMLNamespace    = errors.New("the problem element is not in the 'urn:ietf:rfc:7807' namespace")
+	ErrDuplicateMember = errors.New("member is duplicated")
 )
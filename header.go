@@ -0,0 +1,66 @@
+package problem
+
+import (
+	"net/http"
+	"strings"
+)
+
+// HeaderProvider is implemented by problems carrying their own response headers, like
+// WWW-Authenticate for 401 Unauthorized or Allow for 405 Method Not Allowed problems.
+//
+// The serving helpers set these headers in the response before writing the status code,
+// replacing the values already set, except for Vary and Link, whose values are added. Headers
+// describing the body (Content-Type, Content-Length, X-Content-Type-Options) cannot be overridden.
+//
+// [RegisteredProblem] implements it, see [RegisteredProblem.SetHeader].
+type HeaderProvider interface {
+	Header() http.Header
+}
+
+// Header returns the response headers set with [RegisteredProblem.SetHeader] and
+// [RegisteredProblem.AddHeader], or nil if there are none.
+func (r RegisteredProblem) Header() http.Header {
+	if r.header == nil {
+		return nil
+	}
+	return *r.header
+}
+
+// SetHeader sets the response header key to value, replacing any existing values, and returns r.
+func (r *RegisteredProblem) SetHeader(key, value string) *RegisteredProblem {
+	r.headers().Set(key, value)
+	return r
+}
+
+// AddHeader adds value to the response header key, and returns r.
+func (r *RegisteredProblem) AddHeader(key, value string) *RegisteredProblem {
+	r.headers().Add(key, value)
+	return r
+}
+
+func (r *RegisteredProblem) headers() http.Header {
+	if r.header == nil {
+		r.header = &http.Header{}
+	}
+	return *r.header
+}
+
+// NewUnauthorized returns a 401 Unauthorized *[RegisteredProblem], with a WWW-Authenticate
+// header for every challenge, e.g. `Bearer realm="example"`, as required by
+// https://www.rfc-editor.org/rfc/rfc9110.html#name-401-unauthorized
+func NewUnauthorized(details string, challenges ...string) *RegisteredProblem {
+	p := newRegistered(http.StatusUnauthorized, details, 2)
+	for _, c := range challenges {
+		p.AddHeader("WWW-Authenticate", c)
+	}
+	return p
+}
+
+// NewMethodNotAllowed returns a 405 Method Not Allowed *[RegisteredProblem], with an Allow header
+// listing the allowed methods, as required by
+// https://www.rfc-editor.org/rfc/rfc9110.html#name-405-method-not-allowed
+func NewMethodNotAllowed(details string, allowed ...string) *RegisteredProblem {
+	p := newRegistered(http.StatusMethodNotAllowed, details, 2)
+	p.SetHeader("Allow", strings.Join(allowed, ", "))
+	return p
+}
@@ -0,0 +1,71 @@
+package problem
+
+import (
+	"html/template"
+)
+
+const mediaTypeHTML = "text/html"
+
+// DefaultHTMLTemplate is the template used to render problems as HTML, unless other template is
+// set with [WithHTMLTemplate]. It is executed with a [HTMLData] value.
+var DefaultHTMLTemplate = template.Must(template.New("problem").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Status}} {{.Title}}</title>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+{{with .Detail}}<p>{{.}}</p>
+{{end}}<dl>
+<dt>Type</dt>
+<dd>{{.Type}}</dd>
+<dt>Status</dt>
+<dd>{{.Status}}</dd>
+{{with .Instance}}<dt>Instance</dt>
+<dd>{{.}}</dd>
+{{end}}{{range .Extensions}}<dt>{{.Name}}</dt>
+<dd>{{.Value}}</dd>
+{{end}}</dl>
+</body>
+</html>
+`))
+
+// HTMLData is the data HTML templates are executed with, see [WithHTMLTemplate].
+type HTMLData struct {
+	Type     string
+	Status   int
+	Title    string
+	Detail   string
+	Instance string
+
+	// Extension members of the problem, in the order they are encoded in JSON
+	Extensions []HTMLMember
+
+	// The problem being rendered
+	Problem Problem
+}
+
+// HTMLMember is an extension member of a problem rendered as HTML. Strings values are kept as
+// is, other values are encoded in JSON.
+type HTMLMember struct {
+	Name  string
+	Value string
+}
+
+func newHTMLData(p Problem) HTMLData {
+	d := HTMLData{
+		Type:     p.GetType(),
+		Status:   p.GetStatus(),
+		Title:    p.GetTitle(),
+		Detail:   p.GetDetail(),
+		Instance: p.GetInstance(),
+		Problem:  p,
+	}
+
+	for _, m := range extensionMembers(p) {
+		d.Extensions = append(d.Extensions, HTMLMember{Name: m.name, Value: m.text()})
+	}
+
+	return d
+}
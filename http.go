@@ -2,17 +2,22 @@ package problem
 
 import (
 	"bytes"
-	"encoding/json"
 	"encoding/xml"
+	"fmt"
 	"net/http"
+	"slices"
 	"strconv"
+	"strings"
 	"sync"
 )
 
 type problemHTTPWrapper struct {
 	p Problem
 
+	// Empty means the content type is negotiated per request, see [Serve]
 	contentType string
+
+	c *config
 }
 
 var bufferPool = sync.Pool{
@@ -27,48 +32,272 @@ func getBuffer() *bytes.Buffer {
 	return buf
 }
 
+// encodeProblem writes p into buf in the format of contentType, which must be one of the media
+// types served by this package.
+func encodeProblem(buf *bytes.Buffer, p Problem, contentType string, c *config) error {
+	switch contentType {
+	case MediaTypeProblemJSON, mediaTypeJSON:
+		return c.encoder.encodeJSON(buf, p)
+	case MediaTypeProblemXML, mediaTypeXML:
+		buf.WriteString(xml.Header)
+		return c.encoder.encodeXML(buf, p)
+	case mediaTypeHTML:
+		tmpl := DefaultHTMLTemplate
+		if c.htmlTemplate != nil {
+			tmpl = c.htmlTemplate
+		}
+		return tmpl.Execute(buf, newHTMLData(p))
+	case mediaTypeText:
+		buf.WriteString(Format(p))
+		buf.WriteByte('\n')
+		return nil
+	}
+	return fmt.Errorf("%w: got '%s'", ErrInvalidContentType, contentType)
+}
+
+// contentTypeHeader returns the value of the Content-Type header for mediaType.
+func contentTypeHeader(mediaType string) string {
+	if mediaType == mediaTypeHTML || mediaType == mediaTypeText {
+		return mediaType + "; charset=utf-8"
+	}
+	return mediaType
+}
+
+// prepare returns the problem to be served for r, applying the configured options. p.p is
+// cloned before being modified, since the same handler may serve concurrent requests.
+func (p *problemHTTPWrapper) prepare(w http.ResponseWriter, r *http.Request) Problem {
+	prob, cloned := p.p, false
+
+	// Returns prob after making sure it is a copy of p.p
+	mutable := func() Problem {
+		if !cloned {
+			prob, cloned = cloneProblem(prob), true
+		}
+		return prob
+	}
+
+	h := w.Header()
+
+	if p.c.instanceGenerator != nil && prob.GetInstance() == "" {
+		if instance := p.c.instanceGenerator(r); instance != "" {
+			mutable().setInstance(instance)
+		}
+	}
+
+	if p.c.instanceHeader != "" && prob.GetInstance() != "" {
+		h.Set(p.c.instanceHeader, prob.GetInstance())
+	}
+
+	if t := p.c.translations; t != nil {
+		lang := t.negotiate(r.Header.Values("Accept-Language"))
+
+		// Languages of the served members, untranslated ones are in the default language
+		var langs []string
+		served := func(member, msgLang string, ok bool) {
+			if !ok {
+				if member == "" {
+					return
+				}
+				msgLang = t.defaultTag()
+			}
+			if !slices.Contains(langs, msgLang) {
+				langs = append(langs, msgLang)
+			}
+		}
+
+		title, titleLang, ok := t.translateTitle(lang, prob)
+		if ok {
+			mutable().setTitle(title)
+		}
+		served(prob.GetTitle(), titleLang, ok)
+
+		detail, detailLang, ok := t.translate(lang, prob.GetDetail())
+		if ok {
+			mutable().setDetail(detail)
+		}
+		served(prob.GetDetail(), detailLang, ok)
+
+		if len(langs) == 0 {
+			langs = append(langs, lang)
+		}
+
+		h.Set("Content-Language", strings.Join(langs, ", "))
+		h.Add("Vary", "Accept-Language")
+	}
+
+	return prob
+}
+
 func (p *problemHTTPWrapper) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
+	h := w.Header()
+
+	contentType := p.contentType
+	if contentType == "" {
+		contentType = negotiateContentType(r)
+		h.Add("Vary", "Accept")
+	}
+
+	prob := p.prepare(w, r)
+
+	view := &problemView{Problem: prob}
+
+	if hp, ok := prob.(HeaderProvider); ok {
+		setHeaders(h, hp.Header())
+	}
+
+	if rt := prob.getRetry(); !rt.isZero() {
+		h.Set("Retry-After", rt.header())
+
+		// MapProblem carries the delay in the member itself
+		if p.c.retryAfterMember && !isMapProblem(prob) {
+			view.extra = append(view.extra, retryMember(rt))
+		}
+	}
+
+	if p.c.traceContext {
+		if t, ok := requestTrace(r); ok {
+			view.extra = append(view.extra, t.members()...)
+		}
+	}
+
+	if p.c.debug {
+		view.extra = append(view.extra, debugMembers(prob)...)
+	}
+
+	if p.c.redaction != nil {
+		p.c.redaction.apply(view)
+	}
+
+	if r.Method == http.MethodOptions {
+		// OPTIONS responses carry no representation, only the status and headers
+		h.Del("Content-Type")
+		h.Set("Content-Length", "0")
+		p.runHooks(r, prob)
+		w.WriteHeader(prob.GetStatus())
+		return
+	}
+
 	buf := getBuffer()
 	defer bufferPool.Put(buf)
 
-	h := w.Header()
+	status := prob.GetStatus()
 
-	switch p.contentType {
-	case MediaTypeProblemJSON:
-		_ = json.NewEncoder(buf).Encode(p.p)
-	case MediaTypeProblemXML:
-		buf.WriteString(xml.Header)
-		_ = xml.NewEncoder(buf).Encode(p.p)
+	if err := encodeProblem(buf, view.unwrap(), contentType, p.c); err != nil {
+		// Answer with a generic problem rather than a malformed document, e.g. when a member
+		// of a MapProblem is not a valid XML element name
+		buf.Reset()
+		status = http.StatusInternalServerError
+		prob = NewRegistered(status, "")
+		_ = encodeProblem(buf, prob, contentType, p.c)
 	}
 
-	h.Set("Content-Type", p.contentType)
+	p.runHooks(r, prob)
+
+	h.Set("Content-Type", contentTypeHeader(contentType))
 	h.Set("X-Content-Type-Options", "nosniff")
 	h.Set("Content-Length", strconv.Itoa(buf.Len()))
-	w.WriteHeader(p.p.GetStatus())
-	_, _ = buf.WriteTo(w)
+	w.WriteHeader(status)
+
+	// HEAD responses have the same headers as GET ones, including Content-Length, but no body
+	if r.Method != http.MethodHead {
+		_, _ = buf.WriteTo(w)
+	}
+}
+
+// Headers whose values are added to the ones already in the response, instead of replacing
+// them, when set by a [HeaderProvider].
+var listHeaders = []string{"Vary", "Link"}
+
+// setHeaders sets the headers carried by a problem in h. Values of list headers, like the Vary
+// set by the negotiation or by outer middleware, are kept, other headers are replaced.
+func setHeaders(h, header http.Header) {
+	for key, values := range header {
+		if !slices.Contains(listHeaders, http.CanonicalHeaderKey(key)) {
+			h[key] = slices.Clone(values)
+			continue
+		}
+		for _, v := range values {
+			if !slices.Contains(h.Values(key), v) {
+				h.Add(key, v)
+			}
+		}
+	}
+}
+
+// runHooks calls the hooks with prob, the problem actually served.
+func (p *problemHTTPWrapper) runHooks(r *http.Request, prob Problem) {
+	for _, hook := range p.c.hooks {
+		hook(r, prob)
+	}
 }
 
 // ServeXML returns a Handler that serves the p argument in XML format.
 //
-// p MUST not be a [MapProblem], since it cannot be marshaled to XML.
+// Headers Content-Type is set to 'application/problem+xml' and X-Content-Type-Options is set to 'nosniff',
+// headers carried by p are set if it implements [HeaderProvider]; and finally writes the status
+// code from p.GetStatus().
+//
+// HEAD requests are answered with the same headers but without body, and OPTIONS requests with
+// the status code and the headers carried by p, but without body nor Content-Type.
+//
+// If p cannot be encoded, e.g. a [MapProblem] with a member whose name is not a valid XML
+// element name, a 500 Internal Server Error problem without details is served instead.
 //
-// Headers Content-Type is set to 'application/problem+xml' and X-Content-Type-Options is set to 'nosniff';
-// and finally writes the status code from p.GetStatus().
-func ServeXML(p Problem) http.Handler {
+// opts configure how p is served, see [Option].
+func ServeXML(p Problem, opts ...Option) http.Handler {
 	return &problemHTTPWrapper{
 		p:           p,
 		contentType: MediaTypeProblemXML,
+		c:           newConfig(opts),
 	}
 }
 
 // ServeJSON returns a Handler that serves the p argument in JSON format
 //
 // Headers Content-Type is set to 'application/problem+json' and X-Content-Type-Options is set to
-// 'nosniff'; and finally writes the status code from p.GetStatus().
-func ServeJSON(p Problem) http.Handler {
+// 'nosniff', headers carried by p are set if it implements [HeaderProvider]; and finally writes
+// the status code from p.GetStatus().
+//
+// HEAD requests are answered with the same headers but without body, and OPTIONS requests with
+// the status code and the headers carried by p, but without body nor Content-Type.
+//
+// opts configure how p is served, see [Option].
+func ServeJSON(p Problem, opts ...Option) http.Handler {
 	return &problemHTTPWrapper{
 		p:           p,
 		contentType: MediaTypeProblemJSON,
+		c:           newConfig(opts),
+	}
+}
+
+// Serve returns a Handler that serves the p argument in the format preferred by the client,
+// according to the Accept header of the request.
+//
+// The offered media types are, in order of preference when the client accepts several of them
+// equally: 'application/problem+json', 'application/problem+xml', 'application/json',
+// 'application/xml', 'text/html' and 'text/plain'. Q-values and wildcards ('*/*',
+// 'application/*') are honored, so browsers get HTML while other clients accepting anything get
+// JSON.
+//
+// HTML is rendered with [DefaultHTMLTemplate], or the template set with [WithHTMLTemplate].
+// Plain text is rendered with [Format].
+//
+// If the request has no Accept header or none of the offered media types are acceptable, p is
+// served as 'application/problem+json' rather than answering 406 Not Acceptable.
+//
+// Headers, HEAD and OPTIONS requests are handled the same way as in [ServeJSON] and [ServeXML],
+// and Vary is set to 'Accept'.
+//
+// Accepts the same options as [ServeJSON] and [ServeXML].
+func Serve(p Problem, opts ...Option) http.Handler {
+	return &problemHTTPWrapper{
+		p: p,
+		c: newConfig(opts),
 	}
 }
+
+// Write writes p into w, using the format negotiated from r as described in [Serve].
+func Write(w http.ResponseWriter, r *http.Request, p Problem, opts ...Option) {
+	Serve(p, opts...).ServeHTTP(w, r)
+}
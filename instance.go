@@ -0,0 +1,55 @@
+package problem
+
+import (
+	"crypto/rand"
+	"fmt"
+	"net/http"
+)
+
+// InstanceGenerator returns the "instance" member for a problem served as response to r, see
+// [WithInstance].
+type InstanceGenerator func(r *http.Request) string
+
+// UUIDInstance is an [InstanceGenerator] returning a random (version 4) UUID URN, like
+// 'urn:uuid:f81d4fae-7dec-41d0-a765-00a0c91e6bf6', unique for every problem occurrence.
+func UUIDInstance(r *http.Request) string {
+	var u [16]byte
+	_, _ = rand.Read(u[:])
+
+	// Version 4 and variant bits, https://www.rfc-editor.org/rfc/rfc9562.html#name-uuid-version-4
+	u[6] = (u[6] & 0x0f) | 0x40
+	u[8] = (u[8] & 0x3f) | 0x80
+
+	return fmt.Sprintf("urn:uuid:%x-%x-%x-%x-%x", u[0:4], u[4:6], u[6:8], u[8:10], u[10:16])
+}
+
+// RequestIDInstance returns an [InstanceGenerator] using the value of the request header with
+// the given name, usually 'X-Request-Id', so the problem can be correlated with the request.
+func RequestIDInstance(header string) InstanceGenerator {
+	return func(r *http.Request) string {
+		return r.Header.Get(header)
+	}
+}
+
+// PathInstance is an [InstanceGenerator] using the path of the request URL.
+func PathInstance(r *http.Request) string {
+	return r.URL.Path
+}
+
+// FirstInstance returns an [InstanceGenerator] returning the first non-empty value returned by
+// gens, useful for falling back when the request has no ID:
+//
+//	problem.WithInstance(problem.FirstInstance(
+//	    problem.RequestIDInstance("X-Request-Id"),
+//	    problem.UUIDInstance,
+//	))
+func FirstInstance(gens ...InstanceGenerator) InstanceGenerator {
+	return func(r *http.Request) string {
+		for _, gen := range gens {
+			if instance := gen(r); instance != "" {
+				return instance
+			}
+		}
+		return ""
+	}
+}
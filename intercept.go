@@ -0,0 +1,119 @@
+package problem
+
+import (
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// Maximum number of bytes of the original body kept to be used as the "detail" member.
+const maxInterceptedDetail = 4096
+
+// Intercept returns a middleware that replaces error responses written by next with Problem
+// Details responses, served in the format negotiated by [Serve].
+//
+// A response is replaced when its status code is 400 or greater and its Content-Type is not
+// 'application/problem+json' nor 'application/problem+xml'. This includes the responses of
+// [http.Error], [http.NotFound] and the 404/405 responses of [http.ServeMux].
+//
+// The problem is a [RegisteredProblem] with the status code of the original response, and if
+// the original body was plain text, it is used as the "detail" member. Headers set by next
+// (e.g. Allow on 405 responses) are preserved, except those describing the original body.
+//
+// opts are passed to [Write].
+func Intercept(next http.Handler, opts ...Option) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		iw := &interceptWriter{ResponseWriter: w}
+
+		next.ServeHTTP(iw, r)
+
+		if !iw.intercepted {
+			return
+		}
+
+		h := w.Header()
+		h.Del("Content-Type")
+		h.Del("Content-Length")
+		h.Del("Content-Encoding")
+
+		p := NewRegistered(iw.status, strings.TrimSpace(iw.detail.String()))
+
+		Write(w, r, p, opts...)
+	})
+}
+
+// interceptWriter holds back error responses that are not Problem Details, so they can be
+// replaced after the handler returns.
+type interceptWriter struct {
+	http.ResponseWriter
+
+	wroteHeader bool
+	intercepted bool
+	plainText   bool
+
+	status int
+	detail strings.Builder
+}
+
+func (w *interceptWriter) WriteHeader(statusCode int) {
+	if w.wroteHeader {
+		return
+	}
+
+	if statusCode < 400 || isProblemContentType(w.Header().Get("Content-Type")) {
+		if statusCode >= 200 {
+			w.wroteHeader = true
+		}
+		w.ResponseWriter.WriteHeader(statusCode)
+		return
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(w.Header().Get("Content-Type"))
+
+	w.wroteHeader = true
+	w.intercepted = true
+	w.plainText = mediaType == "" || mediaType == "text/plain"
+	w.status = statusCode
+}
+
+func (w *interceptWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	if !w.intercepted {
+		return w.ResponseWriter.Write(b)
+	}
+
+	if w.plainText {
+		n := min(len(b), maxInterceptedDetail-w.detail.Len())
+		w.detail.Write(b[:n])
+	}
+
+	// Pretend the body was written, it is going to be replaced anyway
+	return len(b), nil
+}
+
+func (w *interceptWriter) Flush() {
+	if w.intercepted {
+		return
+	}
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	_ = http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Unwrap allows [http.ResponseController] to access the underlying ResponseWriter.
+func (w *interceptWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// isProblemContentType reports whether contentType is one of the Problem Details media types.
+func isProblemContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == MediaTypeProblemJSON || mediaType == MediaTypeProblemXML
+}
@@ -0,0 +1,207 @@
+package problem
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Translations holds localised messages for the "title" and "detail" members of problems,
+// grouped by language, see [WithTranslations].
+//
+// When serving a problem, its title is translated using the type URI as message key, falling
+// back to the title itself, so problems created from a [Catalog] and "about:blank" problems
+// (whose title is the status text, e.g. "Not Found") can both be translated. Its detail is
+// translated using the detail itself as message key, e.g. problems created with
+// NewRegistered(http.StatusForbidden, "balance.insufficient").
+//
+// Messages are looked up in the language negotiated from the Accept-Language header, then in its
+// less specific tags (es-MX -> es), and finally in the default language. Members without a
+// translation are served unchanged, their message keys are assumed to be in the default
+// language.
+//
+// The Content-Language header lists the languages of the members actually served, so it is
+// e.g. 'es, en' when the title is translated into Spanish, but the detail only has an English
+// message.
+type Translations struct {
+	defaultLang string
+
+	// Indexed by lowercase language tag, then by message key
+	messages map[string]map[string]string
+
+	// Original casing of the language tags, for Content-Language
+	tags map[string]string
+}
+
+// NewTranslations returns empty Translations, defaultLang is the language used when none of the
+// languages accepted by the client is available.
+func NewTranslations(defaultLang string) *Translations {
+	return &Translations{
+		defaultLang: strings.ToLower(defaultLang),
+		messages:    map[string]map[string]string{},
+		tags:        map[string]string{strings.ToLower(defaultLang): defaultLang},
+	}
+}
+
+// LoadTranslations returns Translations loaded from the JSON files in the root directory of fsys,
+// every file named after the language tag of its messages, e.g. 'en.json', 'es.json' and
+// 'es-MX.json'. Files contain a JSON object mapping message keys to messages:
+//
+//	{
+//	    "https://example.com/probs/out-of-credit": "No tienes suficiente crédito.",
+//	    "Not Found": "No encontrado",
+//	    "balance.insufficient": "Tu saldo actual es insuficiente."
+//	}
+//
+// It is intended to be used with embedded files:
+//
+//	//go:embed translations
+//	var translationsFS embed.FS
+//
+//	sub, _ := fs.Sub(translationsFS, "translations")
+//	t, err := problem.LoadTranslations(sub, "en")
+func LoadTranslations(fsys fs.FS, defaultLang string) (*Translations, error) {
+	files, err := fs.Glob(fsys, "*.json")
+	if err != nil {
+		return nil, err
+	}
+
+	t := NewTranslations(defaultLang)
+
+	for _, file := range files {
+		b, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, err
+		}
+
+		var messages map[string]string
+		if err := json.Unmarshal(b, &messages); err != nil {
+			return nil, fmt.Errorf("problem: loading translations from '%s': %w", file, err)
+		}
+
+		t.Add(strings.TrimSuffix(path.Base(file), ".json"), messages)
+	}
+
+	return t, nil
+}
+
+// Add adds messages in the language lang, replacing existing messages with the same keys.
+func (t *Translations) Add(lang string, messages map[string]string) {
+	key := strings.ToLower(lang)
+
+	if t.messages[key] == nil {
+		t.messages[key] = map[string]string{}
+	}
+	for k, v := range messages {
+		t.messages[key][k] = v
+	}
+
+	t.tags[key] = lang
+}
+
+// negotiate returns the language tag to be used for the given Accept-Language header values.
+func (t *Translations) negotiate(acceptLanguage []string) string {
+	for _, lang := range parseAcceptLanguage(strings.Join(acceptLanguage, ",")) {
+		for ; lang != ""; lang = parentLang(lang) {
+			if _, ok := t.messages[lang]; ok {
+				return t.tags[lang]
+			}
+		}
+	}
+	return t.tags[t.defaultLang]
+}
+
+// translate returns the message for key in lang, or in its fallbacks, and the language of the
+// message: the tag of the bundle it was found in, lang or one of its less specific tags, or the
+// default language otherwise.
+func (t *Translations) translate(lang, key string) (string, string, bool) {
+	if key == "" {
+		return "", "", false
+	}
+
+	for l := strings.ToLower(lang); l != ""; l = parentLang(l) {
+		if msg, ok := t.messages[l][key]; ok {
+			return msg, t.tags[l], true
+		}
+	}
+
+	msg, ok := t.messages[t.defaultLang][key]
+	return msg, t.tags[t.defaultLang], ok
+}
+
+// translateTitle returns the title of p in lang, looked up by type URI and then by title, and
+// the language of the returned title, see [Translations.translate].
+func (t *Translations) translateTitle(lang string, p Problem) (string, string, bool) {
+	if typ := p.GetType(); typ != "about:blank" {
+		if msg, msgLang, ok := t.translate(lang, typ); ok {
+			return msg, msgLang, true
+		}
+	}
+	return t.translate(lang, p.GetTitle())
+}
+
+// defaultTag returns the language tag of the default language.
+func (t *Translations) defaultTag() string {
+	return t.tags[t.defaultLang]
+}
+
+// parentLang returns the language tag without its last subtag, e.g. "es" for "es-mx".
+func parentLang(lang string) string {
+	i := strings.LastIndexByte(lang, '-')
+	if i < 0 {
+		return ""
+	}
+	return lang[:i]
+}
+
+// parseAcceptLanguage returns the lowercase language tags of an Accept-Language header, sorted
+// by q-value in descending order. Wildcards, tags with q=0 and malformed q-values are ignored.
+func parseAcceptLanguage(header string) []string {
+	type weightedLang struct {
+		lang string
+		q    float64
+	}
+
+	var langs []weightedLang
+
+	for _, part := range strings.Split(header, ",") {
+		lang, params, _ := strings.Cut(part, ";")
+		lang = strings.ToLower(strings.TrimSpace(lang))
+		if lang == "" || lang == "*" {
+			continue
+		}
+
+		q := 1.0
+		if key, value, ok := strings.Cut(params, "="); ok && strings.TrimSpace(key) == "q" {
+			var err error
+			q, err = strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err != nil {
+				continue
+			}
+		}
+
+		if q > 0 {
+			langs = append(langs, weightedLang{lang, q})
+		}
+	}
+
+	slices.SortStableFunc(langs, func(a, b weightedLang) int {
+		switch {
+		case a.q > b.q:
+			return -1
+		case a.q < b.q:
+			return 1
+		}
+		return 0
+	})
+
+	tags := make([]string, len(langs))
+	for i, l := range langs {
+		tags[i] = l.lang
+	}
+	return tags
+}
@@ -0,0 +1,105 @@
+package problem
+
+import (
+	"errors"
+	"net/http"
+)
+
+// Mapper maps Go errors into Problem details, using rules based on [errors.Is] and [errors.As].
+//
+// Errors that are problems themselves, or wrap a problem, are mapped into that problem.
+// Otherwise, rules are evaluated in the order they were registered, the first matching rule
+// builds the problem. Errors not matched by any rule are mapped into a generic 500 Internal Server Error
+// problem, without exposing the error message to the client.
+//
+// The zero value is an empty Mapper ready to use. Rules should be registered before the Mapper
+// is used concurrently, typically at program initialization:
+//
+//	var mapper problem.Mapper
+//
+//	func init() {
+//	    mapper.Is(sql.ErrNoRows, http.StatusNotFound, "https://example.org/not-found")
+//
+//	    problem.MapAs(&mapper, func(err *ValidationError) problem.Problem {
+//	        return &CustomProblem{...}
+//	    })
+//	}
+type Mapper struct {
+	rules []func(err error) Problem
+}
+
+// Is registers a rule mapping errors matching target, as reported by [errors.Is], into a
+// [RegisteredProblem] with the given status code and type URI. If typeURI is empty,
+// "about:blank" is used.
+//
+// The problem wraps the mapped error, see [RegisteredProblem.Wrap]. The error message is not
+// used as "detail", if you want to do so use [Mapper.IsFunc].
+func (m *Mapper) Is(target error, statusCode int, typeURI string) {
+	m.IsFunc(target, func(err error) Problem {
+		p := NewRegistered(statusCode, "").Wrap(err)
+		if typeURI != "" {
+			p.Type = typeURI
+		}
+		return p
+	})
+}
+
+// IsFunc registers a rule mapping errors matching target, as reported by [errors.Is], into the
+// problem returned by build, which receives the error being mapped.
+func (m *Mapper) IsFunc(target error, build func(err error) Problem) {
+	m.rules = append(m.rules, func(err error) Problem {
+		if !errors.Is(err, target) {
+			return nil
+		}
+		return build(err)
+	})
+}
+
+// MapAs registers a rule in m mapping errors that can be assigned to E, as reported by
+// [errors.As], into the problem returned by build, which receives the matched error.
+//
+// It is a function instead of a [Mapper] method because methods cannot have type parameters.
+func MapAs[E error](m *Mapper, build func(target E) Problem) {
+	m.rules = append(m.rules, func(err error) Problem {
+		var target E
+		if !errors.As(err, &target) {
+			return nil
+		}
+		return build(target)
+	})
+}
+
+// FromError returns the problem found in the chain of err, or the problem built by the first
+// rule matching err, or a 500 Internal Server Error problem wrapping err if no rule matches.
+//
+// If err is nil, FromError returns nil.
+func (m *Mapper) FromError(err error) Problem {
+	if err == nil {
+		return nil
+	}
+
+	var p Problem
+	if errors.As(err, &p) {
+		return p
+	}
+
+	for _, rule := range m.rules {
+		if p := rule(err); p != nil {
+			return p
+		}
+	}
+
+	return NewRegistered(http.StatusInternalServerError, "").Wrap(err)
+}
+
+// Write maps err into a problem using [Mapper.FromError], and writes it into w using the format
+// negotiated from r as described in [Serve]. opts are passed to [Write].
+//
+// If err is nil, Write does nothing.
+func (m *Mapper) Write(w http.ResponseWriter, r *http.Request, err error, opts ...Option) {
+	p := m.FromError(err)
+	if p == nil {
+		return
+	}
+	Write(w, r, p, opts...)
+}
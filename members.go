@@ -0,0 +1,91 @@
+package problem
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// member is a member of a problem details object, with its value encoded in JSON.
+type member struct {
+	name  string
+	value json.RawMessage
+}
+
+// registeredMembers are the names of the members defined by RFC 9457, in the order they are
+// defined in https://www.rfc-editor.org/rfc/rfc9457.html#name-members-of-a-problem-detail
+var registeredMembers = []string{"type", "status", "title", "detail", "instance"}
+
+func isRegisteredMember(name string) bool {
+	for _, m := range registeredMembers {
+		if m == name {
+			return true
+		}
+	}
+	return false
+}
+
+// problemMembers returns the members of p as they are encoded in JSON, in the same order.
+func problemMembers(p Problem) ([]member, error) {
+	b, err := json.Marshal(p)
+	if err != nil {
+		return nil, err
+	}
+	return objectMembers(b)
+}
+
+// objectMembers returns the members of the JSON object b, in the same order.
+func objectMembers(b []byte) ([]member, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+
+	if tok, err := dec.Token(); err != nil {
+		return nil, err
+	} else if tok != json.Delim('{') {
+		return nil, fmt.Errorf("problem: expected JSON object, got %v", tok)
+	}
+
+	var members []member
+
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return nil, err
+		}
+
+		members = append(members, member{name: tok.(string), value: value})
+	}
+
+	return members, nil
+}
+
+// extensionMembers returns the members of p that are not registered members.
+func extensionMembers(p Problem) []member {
+	members, _ := problemMembers(p)
+
+	extensions := members[:0]
+	for _, m := range members {
+		if !isRegisteredMember(m.name) {
+			extensions = append(extensions, m)
+		}
+	}
+	return extensions
+}
+
+// text returns the value of m as text, strings are returned unquoted, other values as JSON.
+func (m member) text() string {
+	var s string
+	if json.Unmarshal(m.value, &s) == nil {
+		return s
+	}
+	return string(m.value)
+}
+
+// isString reports whether the value of m is a JSON string.
+func (m member) isString() bool {
+	return len(m.value) > 0 && m.value[0] == '"'
+}
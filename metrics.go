@@ -0,0 +1,119 @@
+package problem
+
+import (
+	"cmp"
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Metrics counts the problems served by the serving helpers, by type URI and status code, see
+// [WithMetrics].
+//
+// Counters can be exported with [expvar], since Metrics implements [expvar.Var]:
+//
+//	var metrics problem.Metrics
+//
+//	func init() {
+//	    expvar.Publish("problems", &metrics)
+//	}
+//
+// or in the Prometheus text exposition format, using [Metrics.Handler].
+//
+// The zero value is a Metrics ready to use, and it is safe for concurrent use.
+type Metrics struct {
+	mu     sync.Mutex
+	counts map[metricKey]uint64
+}
+
+type metricKey struct {
+	typ    string
+	status int
+}
+
+type metricCount struct {
+	metricKey
+	count uint64
+}
+
+// Add increments the counter of problems with the type and status code of p.
+func (m *Metrics) Add(p Problem) {
+	key := metricKey{typ: p.GetType(), status: p.GetStatus()}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.counts == nil {
+		m.counts = map[metricKey]uint64{}
+	}
+	m.counts[key]++
+}
+
+// snapshot returns the counters sorted by type URI and status code.
+func (m *Metrics) snapshot() []metricCount {
+	m.mu.Lock()
+	counts := make([]metricCount, 0, len(m.counts))
+	for k, v := range m.counts {
+		counts = append(counts, metricCount{k, v})
+	}
+	m.mu.Unlock()
+
+	slices.SortFunc(counts, func(a, b metricCount) int {
+		return cmp.Or(cmp.Compare(a.typ, b.typ), cmp.Compare(a.status, b.status))
+	})
+	return counts
+}
+
+// String returns the counters as a JSON object, indexed by type URI and then by status code:
+//
+//	{"about:blank": {"404": 12, "500": 1}, "https://example.com/probs/out-of-credit": {"403": 3}}
+//
+// It implements [expvar.Var].
+func (m *Metrics) String() string {
+	counts := map[string]map[string]uint64{}
+	for _, c := range m.snapshot() {
+		if counts[c.typ] == nil {
+			counts[c.typ] = map[string]uint64{}
+		}
+		counts[c.typ][strconv.Itoa(c.status)] = c.count
+	}
+
+	b, _ := json.Marshal(counts)
+	return string(b)
+}
+
+var _ expvar.Var = (*Metrics)(nil)
+
+var prometheusLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// Handler returns a Handler serving the counters in the Prometheus text exposition format, as the
+// problem_responses_total counter with "type" and "status" labels:
+//
+//	# HELP problem_responses_total Problem details responses served, by type URI and status code.
+//	# TYPE problem_responses_total counter
+//	problem_responses_total{type="about:blank",status="404"} 12
+func (m *Metrics) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		buf := getBuffer()
+		defer bufferPool.Put(buf)
+
+		buf.WriteString("# HELP problem_responses_total Problem details responses served, by type URI and status code.\n")
+		buf.WriteString("# TYPE problem_responses_total counter\n")
+
+		for _, c := range m.snapshot() {
+			fmt.Fprintf(buf, "problem_responses_total{type=\"%s\",status=\"%d\"} %d\n",
+				prometheusLabelEscaper.Replace(c.typ), c.status, c.count)
+		}
+
+		h := w.Header()
+		h.Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		h.Set("Content-Length", strconv.Itoa(buf.Len()))
+		w.WriteHeader(http.StatusOK)
+		_, _ = buf.WriteTo(w)
+	})
+}
@@ -0,0 +1,120 @@
+package problem
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Plain media types used as fallbacks when the client does not accept the Problem Details
+// media types.
+const (
+	mediaTypeJSON = "application/json"
+	mediaTypeXML  = "application/xml"
+)
+
+// Media types offered by the negotiating handler, in order of server preference. When two
+// offers are equally acceptable to the client, the first one wins.
+var offers = []string{
+	MediaTypeProblemJSON,
+	MediaTypeProblemXML,
+	mediaTypeJSON,
+	mediaTypeXML,
+	mediaTypeHTML,
+	mediaTypeText,
+}
+
+type acceptRange struct {
+	typ     string
+	subtype string
+	q       float64
+}
+
+// parseAccept parses the value of an Accept header into its media ranges, ranges with
+// malformed media types or q-values are ignored.
+func parseAccept(header string) []acceptRange {
+	var ranges []acceptRange
+
+	for _, part := range strings.Split(header, ",") {
+		mediaRange, params, _ := strings.Cut(part, ";")
+
+		typ, subtype, ok := strings.Cut(strings.ToLower(strings.TrimSpace(mediaRange)), "/")
+		if !ok || typ == "" || subtype == "" || (typ == "*" && subtype != "*") {
+			continue
+		}
+
+		ar := acceptRange{typ: typ, subtype: subtype, q: 1}
+
+		valid := true
+		for _, param := range strings.Split(params, ";") {
+			key, value, _ := strings.Cut(param, "=")
+			if !strings.EqualFold(strings.TrimSpace(key), "q") {
+				continue
+			}
+			q, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err != nil || q < 0 || q > 1 {
+				valid = false
+				break
+			}
+			ar.q = q
+		}
+
+		if valid {
+			ranges = append(ranges, ar)
+		}
+	}
+
+	return ranges
+}
+
+// quality returns the q-value the client assigned to mediaType, taken from the most specific
+// matching range, and false if no range matches at all.
+func quality(ranges []acceptRange, mediaType string) (float64, bool) {
+	typ, subtype, _ := strings.Cut(mediaType, "/")
+
+	q, specificity := 0.0, -1
+
+	for _, ar := range ranges {
+		var s int
+		switch {
+		case ar.typ == typ && ar.subtype == subtype:
+			s = 2
+		case ar.typ == typ && ar.subtype == "*":
+			s = 1
+		case ar.typ == "*" && ar.subtype == "*":
+			s = 0
+		default:
+			continue
+		}
+		if s > specificity {
+			q, specificity = ar.q, s
+		}
+	}
+
+	return q, specificity >= 0
+}
+
+// negotiateContentType selects the media type used to serve p, based on the Accept header
+// of r.
+//
+// If the request has no Accept header, or none of the offered media types are acceptable,
+// then 'application/problem+json' is returned instead of failing with 406 Not Acceptable.
+func negotiateContentType(r *http.Request) string {
+	accept := strings.Join(r.Header.Values("Accept"), ",")
+	if strings.TrimSpace(accept) == "" {
+		return MediaTypeProblemJSON
+	}
+
+	ranges := parseAccept(accept)
+
+	best, bestQ := MediaTypeProblemJSON, 0.0
+
+	for _, offer := range offers {
+		q, ok := quality(ranges, offer)
+		if ok && q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+
+	return best
+}
@@ -0,0 +1,211 @@
+package problem
+
+import (
+	"html/template"
+	"log/slog"
+	"net/http"
+)
+
+// Option configures the behavior of the middlewares and handlers of this package. Options that
+// do not apply to the function receiving them are ignored.
+//
+// The serving helpers ([Serve], [ServeJSON], [ServeXML] and [Write]) accept [WithInstance],
+// [WithInstanceHeader], [WithTranslations], [WithHTMLTemplate], [WithRedaction], [WithHook],
+// [WithDebug], [WithRetryAfterMember], [WithLogger], [WithTraceContext], [WithMetrics] and
+// [WithEncoder]. The middlewares pass the options they receive to the serving helpers.
+type Option func(*config)
+
+type config struct {
+	// Used by Recover
+	panicType string
+	panicHook func(v any, stack []byte)
+
+	// Used by the serving helpers
+	instanceGenerator InstanceGenerator
+	instanceHeader    string
+	translations      *Translations
+	htmlTemplate      *template.Template
+	redaction         *Redaction
+	debug             bool
+	retryAfterMember  bool
+	traceContext      bool
+	hooks             []func(r *http.Request, p Problem)
+	encoder           Encoder
+}
+
+func newConfig(opts []Option) *config {
+	c := &config{}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// WithPanicType sets the "type" member of the problems written by [Recover], by default it is
+// "about:blank".
+func WithPanicType(typeURI string) Option {
+	return func(c *config) {
+		c.panicType = typeURI
+	}
+}
+
+// WithPanicHook sets a function called by [Recover] for every recovered panic, receiving the
+// value passed to panic and the stack trace of the goroutine that panicked. Useful for logging.
+//
+// The hook is called even when the response could not be replaced with a problem, because the
+// headers were already sent.
+func WithPanicHook(hook func(v any, stack []byte)) Option {
+	return func(c *config) {
+		c.panicHook = hook
+	}
+}
+
+// WithInstance makes the serving helpers fill the "instance" member of problems that have none,
+// with the value returned by gen for the current request. If gen returns an empty string, the
+// member is left empty.
+//
+// The served problem is a copy, the problem passed to the serving helper is never modified.
+func WithInstance(gen InstanceGenerator) Option {
+	return func(c *config) {
+		c.instanceGenerator = gen
+	}
+}
+
+// WithInstanceHeader makes the serving helpers echo the "instance" member of the served problem
+// in the response header with the given name (e.g. 'X-Request-Id'), when it is not empty.
+func WithInstanceHeader(name string) Option {
+	return func(c *config) {
+		c.instanceHeader = name
+	}
+}
+
+// WithTranslations makes the serving helpers translate the "title" and "detail" members of the
+// served problems into the language preferred by the client, according to the Accept-Language
+// header of the request, see [Translations] for details.
+//
+// The Content-Language header is set to the languages of the served title and detail, usually
+// the selected language, and Vary to 'Accept-Language'.
+func WithTranslations(t *Translations) Option {
+	return func(c *config) {
+		c.translations = t
+	}
+}
+
+// WithHTMLTemplate sets the template used to render problems when the negotiated media type is
+// 'text/html', instead of [DefaultHTMLTemplate]. tmpl is executed with a [HTMLData] value, it
+// should be a html/template so member values are escaped.
+func WithHTMLTemplate(tmpl *template.Template) Option {
+	return func(c *config) {
+		c.htmlTemplate = tmpl
+	}
+}
+
+// WithRedaction makes the serving helpers redact the problems sent to clients as configured by
+// rd, e.g. to avoid leaking internal details of 5xx problems in production:
+//
+//	problem.WithRedaction(problem.Redaction{
+//	    Detail:            "Please try again later.",
+//	    AllowedExtensions: []string{"trace_id"},
+//	})
+//
+// Hooks set with [WithHook] still receive the problems before being redacted.
+func WithRedaction(rd Redaction) Option {
+	return func(c *config) {
+		c.redaction = &rd
+	}
+}
+
+// WithHook adds a function called by the serving helpers with every problem they serve, before
+// writing the response. The problem has the options applied, except redaction, see
+// [WithRedaction]. If the problem could not be encoded, the hook receives the 500 Internal
+// Server Error problem served instead. hook must not modify p.
+//
+// Multiple hooks can be added, they are called in the order they were added.
+func WithHook(hook func(r *http.Request, p Problem)) Option {
+	return func(c *config) {
+		c.hooks = append(c.hooks, hook)
+	}
+}
+
+// WithDebug makes the serving helpers add the "stack" and "cause" extension members to the
+// served problems, for debugging purposes:
+//
+//   - "stack" contains the stack frames where the problem was created, as 'function file:line'
+//     strings, if stack capturing was enabled with [SetCaptureStacks]. Note that it is a
+//     process-wide setting, unlike the per-handler WithDebug. Problems created without a
+//     captured stack are served without "stack".
+//   - "cause" contains the messages of the chain of errors wrapped by the problem, see
+//     [RegisteredProblem.Wrap].
+//
+// Both are encoded as arrays, in XML following RFC 9457 Appendix B. Members that would be empty
+// are not added.
+//
+// It must not be used in production, since it exposes internal details of the application.
+func WithDebug() Option {
+	return func(c *config) {
+		c.debug = true
+	}
+}
+
+// WithRetryAfterMember makes the serving helpers add the "retry_after" extension member, with
+// the delay in seconds, to problems carrying a retry delay, see [RegisteredProblem.SetRetryAfter].
+// The Retry-After header is always sent, regardless of this option.
+func WithRetryAfterMember() Option {
+	return func(c *config) {
+		c.retryAfterMember = true
+	}
+}
+
+// WithLogger makes the serving helpers log every problem they serve with logger, at a level
+// derived from its status code: Error for 5xx, Warn for 4xx and Info for others.
+//
+// Records include the method and path of the request, and the problem as a "problem" group,
+// with all its members, extension members of custom structs included. Problems are logged
+// before being redacted, see [WithRedaction].
+func WithLogger(logger *slog.Logger) Option {
+	return WithHook(func(r *http.Request, p Problem) {
+		level := logLevel(p.GetStatus())
+		if !logger.Enabled(r.Context(), level) {
+			return
+		}
+
+		logger.LogAttrs(r.Context(), level, "serving problem",
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.String("instance", p.GetInstance()),
+			slog.Attr{Key: "problem", Value: LogValue(p)},
+		)
+	})
+}
+
+// WithTraceContext makes the serving helpers add the "trace_id" and "span_id" extension members
+// to the served problems, taken from the traceparent header of the request, as defined by
+// https://www.w3.org/TR/trace-context/ so client-side errors can be correlated with server
+// traces. Nothing is added if the header is missing or malformed.
+//
+// It works with any tracer propagating W3C Trace Context, see also [TraceContext] for the client
+// side.
+func WithTraceContext() Option {
+	return func(c *config) {
+		c.traceContext = true
+	}
+}
+
+// WithMetrics makes the serving helpers count every problem they serve in m.
+func WithMetrics(m *Metrics) Option {
+	return WithHook(func(r *http.Request, p Problem) {
+		m.Add(p)
+	})
+}
+
+// WithEncoder makes the serving helpers encode the JSON and XML representations of problems as
+// configured by enc, e.g. to omit the empty "instance" member:
+//
+//	problem.WithEncoder(problem.Encoder{OmitEmpty: true, Canonical: true})
+//
+// It is also accepted by [Static].
+func WithEncoder(enc Encoder) Option {
+	return func(c *config) {
+		c.encoder = enc
+	}
+}
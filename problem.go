@@ -2,8 +2,11 @@ package problem
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
+	"maps"
 	"net/http"
+	"reflect"
 
 	tme_json "github.com/otaxhu/type-mismatch-encoding/encoding/json"
 	tme_xml "github.com/otaxhu/type-mismatch-encoding/encoding/xml"
@@ -12,7 +15,12 @@ import (
 // Problem details interface, valid implementors are [MapProblem], [RegisteredProblem] and
 // Custom structs that embeds [RegisteredProblem] (is recommended you embed by value, not a pointer,
 // in that case you need to allocate appropriate memory for that field)
+//
+// Problems are also errors, so they can be returned from any layer of an application and
+// retrieved with errors.As(err, &p), where p is a variable of type Problem.
 type Problem interface {
+	error
+
 	GetType() string
 	GetStatus() int
 	GetTitle() string
@@ -28,13 +36,27 @@ type Problem interface {
 	// For setting "about:blank" to type when the problem detail's type member is not present or
 	// has a JSON type other than string.
 	setTypeAboutBlank()
+
+	// For filling the instance member when serving problems, see [WithInstance]
+	setInstance(instance string)
+
+	// For localising the title and detail members when serving problems, see [WithTranslations]
+	setTitle(title string)
+	setDetail(detail string)
+
+	// For carrying the Retry-After header, see [RetryAfter]
+	getRetry() retry
+	setRetry(r retry)
+
+	// For carrying the trace context, see [TraceContext]
+	getTrace() traceContext
+	setTrace(t traceContext)
 }
 
-// NewMap returns a [MapProblem], this implementation is ONLY suitable for JSON
+// NewMap returns a [MapProblem], this implementation is suitable for both XML and JSON
 // marshaling/unmarshaling.
 //
-// If you want XML support, Instead you should use [NewRegistered] or create your own custom
-// struct that embeds [RegisteredProblem] and set the fields yourself.
+// Extension members can be set with map access notation, see [MapProblem].
 func NewMap(statusCode int, details string) MapProblem {
 	return MapProblem{
 		"status": statusCode,
@@ -52,11 +74,18 @@ func NewMap(statusCode int, details string) MapProblem {
 // then you will need to use [NewMap] or create your own custom struct that embeds
 // [RegisteredProblem] and set the fields yourself.
 func NewRegistered(statusCode int, details string) *RegisteredProblem {
+	return newRegistered(statusCode, details, 2)
+}
+
+// newRegistered implements [NewRegistered] for the constructors, skip being the number of
+// constructor frames, newRegistered included, to skip when capturing the stack.
+func newRegistered(statusCode int, details string, skip int) *RegisteredProblem {
 	return &RegisteredProblem{
 		Type:   "about:blank",
 		Status: statusCode,
 		Title:  http.StatusText(statusCode),
 		Detail: details,
+		stack:  captureStack(skip),
 	}
 }
 
@@ -67,8 +96,8 @@ func NewRegistered(statusCode int, details string) *RegisteredProblem {
 //     Problem is *[MapProblem] (a pointer)
 //
 //  2. Else if Content-Type is 'application/problem+xml' (Problem XML) then the type of the
-//     returned Problem is *[RegisteredProblem] (a pointer) and found extension members are
-//     ignored.
+//     returned Problem is also *[MapProblem] (a pointer), with the extension members decoded as
+//     described in [MapProblem].
 //
 // If Content-Type is not one of the first two above, then an error [ErrInvalidContentType] is
 // returned, you can check for it using errors.Is(err, ErrInvalidContentType)
@@ -76,18 +105,13 @@ func ParseResponse(res *http.Response) (Problem, error) {
 
 	contentType := res.Header.Get("Content-Type")
 
-	var p Problem
-
-	if contentType == MediaTypeProblemJSON {
-		// Use a MapProblem
-		p = &MapProblem{}
-	} else if contentType == MediaTypeProblemXML {
-		// Use RegisteredProblem, gonna lost extension members but it's better than failing
-		p = &RegisteredProblem{}
-	} else {
+	if contentType != MediaTypeProblemJSON && contentType != MediaTypeProblemXML {
 		return nil, fmt.Errorf("%w: got '%s'", ErrInvalidContentType, contentType)
 	}
 
+	// Use a MapProblem, so extension members are not lost
+	p := &MapProblem{}
+
 	err := ParseResponseCustom(res, p)
 	if err != nil {
 		return nil, err
@@ -102,11 +126,22 @@ func ParseResponse(res *http.Response) (Problem, error) {
 //
 //   - p must be a pointer.
 //   - p must not be nil nor point to nil.
-//   - If Content-Type is 'application/problem+xml' (Problem XML), then p must not be a [MapProblem]
 //
 // If you followed this constraints, then you should get p populated with the Problem details
 // values and no errors.
+//
+// If the response has a Retry-After header, its value is available with [RetryAfter], except
+// for a [MapProblem], see [ParseRetryAfter]. If the problem has "trace_id" and "span_id"
+// members, they are available with [TraceContext].
+//
+// Members whose value type does not match the expected one are ignored, as mandated by
+// RFC 9457. Use [ParseResponseStrict] to get every deviation from the RFC reported instead.
 func ParseResponseCustom(res *http.Response, p Problem) error {
+	return parseResponse(res, p, false)
+}
+
+// parseResponse implements [ParseResponseCustom], and [ParseResponseStrict] if strict is true.
+func parseResponse(res *http.Response, p Problem, strict bool) error {
 	contentType := res.Header.Get("Content-Type")
 
 	if contentType != MediaTypeProblemJSON && contentType != MediaTypeProblemXML {
@@ -124,6 +159,36 @@ func ParseResponseCustom(res *http.Response, p Problem) error {
 
 	b := buf.Bytes()
 
+	var conformance error
+	if strict {
+		if violations := checkConformance(b, contentType, res.StatusCode, p); len(violations) > 0 {
+			conformance = &ConformanceError{Violations: violations}
+		}
+	}
+
+	if err := decodeProblem(b, contentType, p); err != nil {
+		if conformance != nil {
+			// The violations may explain why decoding failed
+			return errors.Join(conformance, err)
+		}
+		return err
+	}
+
+	p.setStatus(res.StatusCode)
+
+	if r, ok := parseRetryAfter(res.Header.Get("Retry-After")); ok && p.getRetry().isZero() {
+		p.setRetry(r)
+	}
+
+	if p.getTrace().isZero() {
+		p.setTrace(decodeTrace(b, contentType))
+	}
+
+	return conformance
+}
+
+// decodeProblem decodes the document b, in the format of contentType, into p.
+func decodeProblem(b []byte, contentType string, p Problem) error {
 	// See issue https://github.com/otaxhu/problem/issues/14
 	//
 	// This structs checks that "type" is present or has an incorrect type (on JSON)
@@ -178,10 +243,16 @@ func ParseResponseCustom(res *http.Response, p Problem) error {
 
 	case MediaTypeProblemXML:
 
-		dec := tme_xml.NewDecoder(br)
-		dec.AllowTypeMismatch = true
+		var err error
 
-		err := dec.Decode(p)
+		if u, ok := p.(problemXMLUnmarshaler); ok {
+			err = u.unmarshalProblemXML(b)
+		} else {
+			dec := tme_xml.NewDecoder(br)
+			dec.AllowTypeMismatch = true
+
+			err = dec.Decode(p)
+		}
 		if err != nil {
 			return err
 		}
@@ -190,7 +261,7 @@ func ParseResponseCustom(res *http.Response, p Problem) error {
 
 			br.Reset(b)
 
-			dec = tme_xml.NewDecoder(br)
+			dec := tme_xml.NewDecoder(br)
 			dec.AllowTypeMismatch = true
 
 			err = dec.Decode(&checkTypeXML)
@@ -204,7 +275,33 @@ func ParseResponseCustom(res *http.Response, p Problem) error {
 		}
 	}
 
-	p.setStatus(res.StatusCode)
-
 	return nil
 }
+
+// problemXMLUnmarshaler is implemented by problems that cannot be decoded from XML by the
+// lenient decoder on its own, since it does not call the standard [encoding/xml.Unmarshaler]
+// interface.
+type problemXMLUnmarshaler interface {
+	unmarshalProblemXML(b []byte) error
+}
+
+// cloneProblem returns a shallow copy of p, so its members can be modified without affecting p.
+func cloneProblem(p Problem) Problem {
+	switch v := p.(type) {
+	case MapProblem:
+		return maps.Clone(v)
+	case *MapProblem:
+		m := maps.Clone(*v)
+		return &m
+	}
+
+	rv := reflect.ValueOf(p)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		// Either already a copy, or nothing to copy
+		return p
+	}
+
+	c := reflect.New(rv.Elem().Type())
+	c.Elem().Set(rv.Elem())
+	return c.Interface().(Problem)
+}
@@ -5,12 +5,20 @@ import (
 	"encoding/json"
 	"encoding/xml"
 	"errors"
+	"fmt"
+	"html/template"
 	"io"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
 	"testing"
+	"testing/fstest"
 	"testing/iotest"
+	"time"
 )
 
 func equalProblems(a Problem, b Problem) bool {
@@ -660,3 +668,2749 @@ func TestMapProblemMarshaling(t *testing.T) {
 		})
 	}
 }
+
+func TestMapProblemXML(t *testing.T) {
+	p := NewMap(http.StatusForbidden, "Your current balance is 30")
+	p["balance"] = 30
+	p["accounts"] = []string{"/account/12345", "/account/67890"}
+	p["limits"] = map[string]any{"daily": 100, "enabled": true}
+	p["note"] = nil
+
+	expectedXML := `<problem xmlns="urn:ietf:rfc:7807">` +
+		`<type>about:blank</type><status>403</status><title>Forbidden</title>` +
+		`<detail>Your current balance is 30</detail>` +
+		`<accounts><i>/account/12345</i><i>/account/67890</i></accounts>` +
+		`<balance>30</balance>` +
+		`<limits><daily>100</daily><enabled>true</enabled></limits>` +
+		`<note></note>` +
+		`</problem>`
+
+	b, err := xml.Marshal(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != expectedXML {
+		t.Errorf("expected %s, got %s", expectedXML, b)
+	}
+
+	recorder := httptest.NewRecorder()
+	ServeXML(p).ServeHTTP(recorder, httptest.NewRequest("", "/", nil))
+
+	outProblem, err := ParseResponse(recorder.Result())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !equalProblems(outProblem, p) {
+		t.Errorf("expected %+v, got %+v", p, outProblem)
+	}
+
+	// XML has no value types, leaf values are decoded as strings
+	expectedMembers := MapProblem{
+		"balance":  "30",
+		"accounts": []any{"/account/12345", "/account/67890"},
+		"limits":   map[string]any{"daily": "100", "enabled": "true"},
+		"note":     "",
+	}
+
+	m := *outProblem.(*MapProblem)
+	for name, expected := range expectedMembers {
+		if fmt.Sprint(m[name]) != fmt.Sprint(expected) {
+			t.Errorf("expected %s to be %v, got %v", name, expected, m[name])
+		}
+	}
+	if _, ok := m["instance"]; ok {
+		t.Errorf("expected no instance member, got %v", m["instance"])
+	}
+
+	for name, bad := range map[string]MapProblem{
+		"Invalid Member Name":        {"bad key": 1},
+		"Invalid Nested Member Name": {"limits": map[string]any{"1st": 1}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			bad["status"] = http.StatusBadRequest
+
+			if _, err := xml.Marshal(bad); err == nil {
+				t.Errorf("expected error to be non-nil, got <nil>")
+			}
+
+			var hookStatus int
+			hook := WithHook(func(r *http.Request, p Problem) {
+				hookStatus = p.GetStatus()
+			})
+
+			recorder := httptest.NewRecorder()
+			ServeXML(bad, hook).ServeHTTP(recorder, httptest.NewRequest("", "/", nil))
+
+			if recorder.Code != http.StatusInternalServerError {
+				t.Errorf("expected %d, got %d", http.StatusInternalServerError, recorder.Code)
+			}
+			if hookStatus != http.StatusInternalServerError {
+				t.Errorf("expected hook to receive the served %d, got %d", http.StatusInternalServerError, hookStatus)
+			}
+			var out RegisteredProblem
+			if err := xml.Unmarshal(recorder.Body.Bytes(), &out); err != nil {
+				t.Errorf("expected a well-formed document, got %v: %s", err, recorder.Body.String())
+			}
+		})
+	}
+
+	testCases := map[string]struct {
+		InputXML      string
+		ExpectedError bool
+	}{
+		"Indented": {
+			InputXML: xml.Header + `
+				<problem xmlns="urn:ietf:rfc:7807">
+					<status>400</status>
+					<accounts>
+						<i>/account/12345</i>
+					</accounts>
+				</problem>
+			`,
+		},
+		"Missing Namespace": {
+			InputXML:      `<problem><status>400</status></problem>`,
+			ExpectedError: true,
+		},
+		"Wrong Root Element": {
+			InputXML:      `<error xmlns="urn:ietf:rfc:7807"><status>400</status></error>`,
+			ExpectedError: true,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			var m MapProblem
+			err := xml.Unmarshal([]byte(tc.InputXML), &m)
+			if tc.ExpectedError && err == nil {
+				t.Fatalf("expected error to be non-nil, got <nil>")
+			} else if !tc.ExpectedError && err != nil {
+				t.Fatalf("expected error to be nil, got %v", err)
+			}
+			if err != nil {
+				return
+			}
+			if m.GetStatus() != http.StatusBadRequest {
+				t.Errorf("expected status %d, got %d", http.StatusBadRequest, m.GetStatus())
+			}
+			if accounts := fmt.Sprint(m["accounts"]); accounts != "[/account/12345]" {
+				t.Errorf("expected accounts [/account/12345], got %s", accounts)
+			}
+		})
+	}
+}
+
+func TestServeNegotiation(t *testing.T) {
+	testCases := map[string]struct {
+		InputProblem        Problem
+		InputAccept         []string
+		ExpectedContentType string
+	}{
+		"No Accept": {
+			InputProblem:        NewRegistered(http.StatusBadRequest, "test"),
+			ExpectedContentType: MediaTypeProblemJSON,
+		},
+		"Problem XML": {
+			InputProblem:        NewRegistered(http.StatusBadRequest, "test"),
+			InputAccept:         []string{MediaTypeProblemXML},
+			ExpectedContentType: MediaTypeProblemXML,
+		},
+		"Plain JSON Fallback": {
+			InputProblem:        NewRegistered(http.StatusBadRequest, "test"),
+			InputAccept:         []string{"application/json"},
+			ExpectedContentType: "application/json",
+		},
+		"Plain XML Fallback": {
+			InputProblem:        NewRegistered(http.StatusBadRequest, "test"),
+			InputAccept:         []string{"image/png, application/xml;q=0.9"},
+			ExpectedContentType: "application/xml",
+		},
+		"Q-Values": {
+			InputProblem:        NewRegistered(http.StatusBadRequest, "test"),
+			InputAccept:         []string{"application/problem+json;q=0.5, application/problem+xml"},
+			ExpectedContentType: MediaTypeProblemXML,
+		},
+		"Multiple Headers": {
+			InputProblem:        NewRegistered(http.StatusBadRequest, "test"),
+			InputAccept:         []string{"application/problem+json;q=0.5", "application/problem+xml;q=0.8"},
+			ExpectedContentType: MediaTypeProblemXML,
+		},
+		"Wildcard": {
+			InputProblem:        NewRegistered(http.StatusBadRequest, "test"),
+			InputAccept:         []string{"*/*"},
+			ExpectedContentType: MediaTypeProblemJSON,
+		},
+		"Subtype Wildcard With Exclusion": {
+			InputProblem:        NewRegistered(http.StatusBadRequest, "test"),
+			InputAccept:         []string{"application/*, application/problem+json;q=0"},
+			ExpectedContentType: MediaTypeProblemXML,
+		},
+		"Nothing Acceptable": {
+			InputProblem:        NewRegistered(http.StatusBadRequest, "test"),
+			InputAccept:         []string{"image/png"},
+			ExpectedContentType: MediaTypeProblemJSON,
+		},
+		"Malformed Accept": {
+			InputProblem:        NewRegistered(http.StatusBadRequest, "test"),
+			InputAccept:         []string{"application/problem+xml;q=abc, application/json"},
+			ExpectedContentType: "application/json",
+		},
+		"Browser": {
+			InputProblem:        NewRegistered(http.StatusBadRequest, "test"),
+			InputAccept:         []string{"text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8"},
+			ExpectedContentType: "text/html; charset=utf-8",
+		},
+		"MapProblem XML": {
+			InputProblem:        NewMap(http.StatusBadRequest, "test"),
+			InputAccept:         []string{MediaTypeProblemXML},
+			ExpectedContentType: MediaTypeProblemXML,
+		},
+		"MapProblem Plain XML Fallback": {
+			InputProblem:        NewMap(http.StatusBadRequest, "test"),
+			InputAccept:         []string{"application/xml, application/json;q=0.1"},
+			ExpectedContentType: "application/xml",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			req := httptest.NewRequest("", "/", nil)
+			for _, accept := range tc.InputAccept {
+				req.Header.Add("Accept", accept)
+			}
+
+			Serve(tc.InputProblem).ServeHTTP(recorder, req)
+
+			res := recorder.Result()
+
+			contentType := res.Header.Get("Content-Type")
+			if contentType != tc.ExpectedContentType {
+				t.Fatalf("expected %s, got %s", tc.ExpectedContentType, contentType)
+			}
+
+			if vary := res.Header.Get("Vary"); vary != "Accept" {
+				t.Errorf("expected Vary to be Accept, got %s", vary)
+			}
+
+			if res.StatusCode != tc.InputProblem.GetStatus() {
+				t.Errorf("expected %d, got %d", tc.InputProblem.GetStatus(), res.StatusCode)
+			}
+
+			var outProblem Problem
+			switch contentType {
+			case MediaTypeProblemJSON, "application/json":
+				outProblem = &MapProblem{}
+				err := json.NewDecoder(res.Body).Decode(outProblem)
+				if err != nil {
+					t.Fatal(err)
+				}
+				// JSON numbers are decoded as float64
+				outProblem.setStatus(tc.InputProblem.GetStatus())
+			case MediaTypeProblemXML, "application/xml":
+				outProblem = &RegisteredProblem{}
+				err := xml.NewDecoder(res.Body).Decode(outProblem)
+				if err != nil {
+					t.Fatal(err)
+				}
+			default:
+				return
+			}
+
+			if !equalProblems(outProblem, tc.InputProblem) {
+				t.Errorf("expected %+v, got %+v", tc.InputProblem, outProblem)
+			}
+		})
+	}
+}
+
+func TestRecover(t *testing.T) {
+	testCases := map[string]struct {
+		InputHandler        http.HandlerFunc
+		InputOptions        []Option
+		ExpectedStatus      int
+		ExpectedContentType string
+		ExpectedType        string
+		ExpectedHookValue   any
+	}{
+		"No Panic": {
+			InputHandler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNoContent)
+			},
+			ExpectedStatus: http.StatusNoContent,
+		},
+		"Panic": {
+			InputHandler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/plain")
+				panic("test")
+			},
+			ExpectedStatus:      http.StatusInternalServerError,
+			ExpectedContentType: MediaTypeProblemJSON,
+			ExpectedType:        "about:blank",
+			ExpectedHookValue:   "test",
+		},
+		"Panic With Type": {
+			InputHandler: func(w http.ResponseWriter, r *http.Request) {
+				panic("test")
+			},
+			InputOptions:        []Option{WithPanicType("https://example.org/panic")},
+			ExpectedStatus:      http.StatusInternalServerError,
+			ExpectedContentType: MediaTypeProblemJSON,
+			ExpectedType:        "https://example.org/panic",
+			ExpectedHookValue:   "test",
+		},
+		"Panic After Headers Sent": {
+			InputHandler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/plain")
+				w.WriteHeader(http.StatusOK)
+				panic("test")
+			},
+			ExpectedStatus:      http.StatusOK,
+			ExpectedContentType: "text/plain",
+			ExpectedHookValue:   "test",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			var hookValue any
+			var hookStack []byte
+			opts := append(tc.InputOptions, WithPanicHook(func(v any, stack []byte) {
+				hookValue, hookStack = v, stack
+			}))
+
+			recorder := httptest.NewRecorder()
+			req := httptest.NewRequest("", "/", nil)
+
+			Recover(tc.InputHandler, opts...).ServeHTTP(recorder, req)
+
+			res := recorder.Result()
+
+			if res.StatusCode != tc.ExpectedStatus {
+				t.Errorf("expected %d, got %d", tc.ExpectedStatus, res.StatusCode)
+			}
+
+			if contentType := res.Header.Get("Content-Type"); contentType != tc.ExpectedContentType {
+				t.Errorf("expected %s, got %s", tc.ExpectedContentType, contentType)
+			}
+
+			if hookValue != tc.ExpectedHookValue {
+				t.Errorf("expected hook value %v, got %v", tc.ExpectedHookValue, hookValue)
+			}
+
+			if tc.ExpectedHookValue != nil && len(hookStack) == 0 {
+				t.Errorf("expected hook stack to be non-empty")
+			}
+
+			if tc.ExpectedType == "" {
+				return
+			}
+
+			p, err := ParseResponse(res)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if p.GetType() != tc.ExpectedType {
+				t.Errorf("expected %s, got %s", tc.ExpectedType, p.GetType())
+			}
+
+			if p.GetDetail() != "" {
+				t.Errorf("expected detail to be empty, got %s", p.GetDetail())
+			}
+		})
+	}
+}
+
+func TestRecoverKeepsOuterHeaders(t *testing.T) {
+	handler := Recover(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Inner", "dropped")
+		w.Header().Set("X-Request-Id", "overwritten")
+		panic("test")
+	}))
+
+	outer := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("X-Request-Id", "abc")
+		handler.ServeHTTP(w, r)
+	})
+
+	recorder := httptest.NewRecorder()
+	outer.ServeHTTP(recorder, httptest.NewRequest("", "/", nil))
+
+	if recorder.Code != http.StatusInternalServerError {
+		t.Errorf("expected %d, got %d", http.StatusInternalServerError, recorder.Code)
+	}
+
+	expected := map[string]string{
+		"Access-Control-Allow-Origin": "*",
+		"X-Request-Id":                "abc",
+		"X-Inner":                     "",
+		"Content-Type":                MediaTypeProblemJSON,
+	}
+	for key, value := range expected {
+		if got := recorder.Header().Get(key); got != value {
+			t.Errorf("expected %s %q, got %q", key, value, got)
+		}
+	}
+}
+
+func TestRecoverAbortHandler(t *testing.T) {
+	defer func() {
+		if v := recover(); v != http.ErrAbortHandler {
+			t.Errorf("expected %v, got %v", http.ErrAbortHandler, v)
+		}
+	}()
+
+	handler := Recover(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("", "/", nil))
+}
+
+func TestIntercept(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /ok", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte("ok"))
+	})
+	mux.HandleFunc("GET /error", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "something failed", http.StatusConflict)
+	})
+	mux.HandleFunc("GET /html", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("<h1>Bad</h1>"))
+	})
+	mux.HandleFunc("GET /problem", func(w http.ResponseWriter, r *http.Request) {
+		ServeXML(NewRegistered(http.StatusTeapot, "already a problem")).ServeHTTP(w, r)
+	})
+
+	testCases := map[string]struct {
+		InputPath           string
+		InputMethod         string
+		ExpectedStatus      int
+		ExpectedContentType string
+		ExpectedDetail      string
+		ExpectedAllow       string
+	}{
+		"Pass Through Success": {
+			InputPath:           "/ok",
+			ExpectedStatus:      http.StatusOK,
+			ExpectedContentType: "text/plain",
+		},
+		"http.Error": {
+			InputPath:           "/error",
+			ExpectedStatus:      http.StatusConflict,
+			ExpectedContentType: MediaTypeProblemJSON,
+			ExpectedDetail:      "something failed",
+		},
+		"Not Plain Text": {
+			InputPath:           "/html",
+			ExpectedStatus:      http.StatusBadRequest,
+			ExpectedContentType: MediaTypeProblemJSON,
+			ExpectedDetail:      "",
+		},
+		"Not Found": {
+			InputPath:           "/missing",
+			ExpectedStatus:      http.StatusNotFound,
+			ExpectedContentType: MediaTypeProblemJSON,
+			ExpectedDetail:      "404 page not found",
+		},
+		"Method Not Allowed": {
+			InputPath:           "/error",
+			InputMethod:         http.MethodPost,
+			ExpectedStatus:      http.StatusMethodNotAllowed,
+			ExpectedContentType: MediaTypeProblemJSON,
+			ExpectedDetail:      "Method Not Allowed",
+			ExpectedAllow:       "GET, HEAD",
+		},
+		"Pass Through Problem": {
+			InputPath:           "/problem",
+			ExpectedStatus:      http.StatusTeapot,
+			ExpectedContentType: MediaTypeProblemXML,
+			ExpectedDetail:      "already a problem",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			req := httptest.NewRequest(tc.InputMethod, tc.InputPath, nil)
+
+			Intercept(mux).ServeHTTP(recorder, req)
+
+			res := recorder.Result()
+
+			if res.StatusCode != tc.ExpectedStatus {
+				t.Errorf("expected %d, got %d", tc.ExpectedStatus, res.StatusCode)
+			}
+
+			contentType := res.Header.Get("Content-Type")
+			if contentType != tc.ExpectedContentType {
+				t.Fatalf("expected %s, got %s", tc.ExpectedContentType, contentType)
+			}
+
+			if allow := res.Header.Get("Allow"); allow != tc.ExpectedAllow {
+				t.Errorf("expected Allow %s, got %s", tc.ExpectedAllow, allow)
+			}
+
+			if !isProblemContentType(contentType) {
+				return
+			}
+
+			p, err := ParseResponse(res)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if p.GetDetail() != tc.ExpectedDetail {
+				t.Errorf("expected %s, got %s", tc.ExpectedDetail, p.GetDetail())
+			}
+		})
+	}
+}
+
+type testValidationError struct {
+	Field string
+}
+
+func (e *testValidationError) Error() string {
+	return "invalid field " + e.Field
+}
+
+type testFieldProblem struct {
+	RegisteredProblem
+	Field string `json:"field" xml:"field"`
+}
+
+func TestMapper(t *testing.T) {
+	errNotFound := errors.New("not found")
+	errConflict := errors.New("conflict")
+
+	var mapper Mapper
+	mapper.Is(errNotFound, http.StatusNotFound, "https://example.org/not-found")
+	mapper.IsFunc(errConflict, func(err error) Problem {
+		return NewRegistered(http.StatusConflict, err.Error())
+	})
+	MapAs(&mapper, func(err *testValidationError) Problem {
+		return &testFieldProblem{
+			RegisteredProblem: *NewRegistered(http.StatusUnprocessableEntity, err.Error()),
+			Field:             err.Field,
+		}
+	})
+
+	testCases := map[string]struct {
+		InputError      error
+		ExpectedProblem Problem
+		WrapsError      bool
+	}{
+		"Nil": {
+			InputError:      nil,
+			ExpectedProblem: nil,
+		},
+		"Is": {
+			InputError: fmt.Errorf("wrapped: %w", errNotFound),
+			ExpectedProblem: &RegisteredProblem{
+				Type:   "https://example.org/not-found",
+				Status: http.StatusNotFound,
+				Title:  "Not Found",
+			},
+			WrapsError: true,
+		},
+		"IsFunc": {
+			InputError: errConflict,
+			ExpectedProblem: &RegisteredProblem{
+				Type:   "about:blank",
+				Status: http.StatusConflict,
+				Title:  "Conflict",
+				Detail: "conflict",
+			},
+		},
+		"MapAs": {
+			InputError: fmt.Errorf("wrapped: %w", &testValidationError{Field: "name"}),
+			ExpectedProblem: &RegisteredProblem{
+				Type:   "about:blank",
+				Status: http.StatusUnprocessableEntity,
+				Title:  "Unprocessable Entity",
+				Detail: "invalid field name",
+			},
+		},
+		"Unmatched": {
+			InputError: errors.New("secret database error"),
+			ExpectedProblem: &RegisteredProblem{
+				Type:   "about:blank",
+				Status: http.StatusInternalServerError,
+				Title:  "Internal Server Error",
+			},
+			WrapsError: true,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			outProblem := mapper.FromError(tc.InputError)
+
+			recorder := httptest.NewRecorder()
+			req := httptest.NewRequest("", "/", nil)
+
+			mapper.Write(recorder, req, tc.InputError)
+
+			if tc.ExpectedProblem == nil {
+				if outProblem != nil {
+					t.Fatalf("expected <nil>, got %+v", outProblem)
+				}
+				if recorder.Code != http.StatusOK || recorder.Body.Len() != 0 {
+					t.Errorf("expected nothing written, got %d %q", recorder.Code, recorder.Body.String())
+				}
+				return
+			}
+
+			if !equalProblems(outProblem, tc.ExpectedProblem) {
+				t.Errorf("expected %+v, got %+v", tc.ExpectedProblem, outProblem)
+			}
+
+			if tc.WrapsError && !errors.Is(outProblem, tc.InputError) {
+				t.Errorf("expected the problem to wrap %v", tc.InputError)
+			}
+
+			if recorder.Code != tc.ExpectedProblem.GetStatus() {
+				t.Errorf("expected %d, got %d", tc.ExpectedProblem.GetStatus(), recorder.Code)
+			}
+		})
+	}
+
+	p := mapper.FromError(&testValidationError{Field: "name"})
+	if fp, ok := p.(*testFieldProblem); !ok || fp.Field != "name" {
+		t.Errorf("expected *testFieldProblem with field name, got %+v", p)
+	}
+}
+
+func TestCatalogRegister(t *testing.T) {
+	var catalog Catalog
+
+	testCases := []struct {
+		Name          string
+		InputType     Type
+		ExpectedType  Type
+		ExpectedError error
+	}{
+		{
+			Name: "OK",
+			InputType: Type{
+				URI:    "https://example.com/probs/out-of-credit",
+				Title:  "You do not have enough credit.",
+				Status: http.StatusForbidden,
+			},
+			ExpectedType: Type{
+				URI:    "https://example.com/probs/out-of-credit",
+				Title:  "You do not have enough credit.",
+				Status: http.StatusForbidden,
+			},
+		},
+		{
+			Name: "Default Title",
+			InputType: Type{
+				URI:    "https://example.com/probs/not-found",
+				Status: http.StatusNotFound,
+			},
+			ExpectedType: Type{
+				URI:    "https://example.com/probs/not-found",
+				Title:  "Not Found",
+				Status: http.StatusNotFound,
+			},
+		},
+		{
+			Name: "Duplicate",
+			InputType: Type{
+				URI:    "https://example.com/probs/out-of-credit",
+				Status: http.StatusForbidden,
+			},
+			ExpectedError: ErrDuplicateType,
+		},
+		{
+			Name: "Missing URI",
+			InputType: Type{
+				Status: http.StatusForbidden,
+			},
+			ExpectedError: ErrInvalidType,
+		},
+		{
+			Name: "Bad URI",
+			InputType: Type{
+				URI:    "https://exa mple.com/%zz",
+				Status: http.StatusForbidden,
+			},
+			ExpectedError: ErrInvalidType,
+		},
+		{
+			Name: "Bad Status",
+			InputType: Type{
+				URI:    "https://example.com/probs/bad-status",
+				Status: 1000,
+			},
+			ExpectedError: ErrInvalidType,
+		},
+	}
+
+	// Cases are run sequentially, since they depend on previous registrations
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			outType, err := catalog.Register(tc.InputType)
+			if !errors.Is(err, tc.ExpectedError) {
+				t.Fatalf("expected error %v, got %v", tc.ExpectedError, err)
+			}
+			if err != nil {
+				return
+			}
+			if outType != tc.ExpectedType {
+				t.Errorf("expected %+v, got %+v", tc.ExpectedType, outType)
+			}
+			if lookedUp, ok := catalog.Lookup(tc.InputType.URI); !ok || lookedUp != tc.ExpectedType {
+				t.Errorf("expected %+v, got %+v", tc.ExpectedType, lookedUp)
+			}
+		})
+	}
+
+	if n := len(catalog.Types()); n != 2 {
+		t.Errorf("expected 2 types, got %d", n)
+	}
+}
+
+func TestCatalogMatch(t *testing.T) {
+	var catalog Catalog
+
+	outOfCredit := catalog.MustRegister(Type{
+		URI:    "https://example.com/probs/out-of-credit",
+		Title:  "You do not have enough credit.",
+		Status: http.StatusForbidden,
+	})
+
+	p := outOfCredit.New("Your current balance is 30, but that costs 50.")
+
+	expectedProblem := &RegisteredProblem{
+		Type:   "https://example.com/probs/out-of-credit",
+		Status: http.StatusForbidden,
+		Title:  "You do not have enough credit.",
+		Detail: "Your current balance is 30, but that costs 50.",
+	}
+
+	if !equalProblems(p, expectedProblem) {
+		t.Errorf("expected %+v, got %+v", expectedProblem, p)
+	}
+
+	recorder := httptest.NewRecorder()
+	ServeJSON(p).ServeHTTP(recorder, httptest.NewRequest("", "/", nil))
+
+	parsed, err := ParseResponse(recorder.Result())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if matched, ok := catalog.Match(parsed); !ok || matched != outOfCredit {
+		t.Errorf("expected %+v, got %+v", outOfCredit, matched)
+	}
+
+	if _, ok := catalog.Match(NewRegistered(http.StatusForbidden, "")); ok {
+		t.Errorf("expected about:blank not to match")
+	}
+}
+
+func TestCatalogDocsHandler(t *testing.T) {
+	var catalog Catalog
+
+	catalog.MustRegister(Type{
+		URI:         "https://example.com/probs/out-of-credit",
+		Title:       "You do not have enough credit.",
+		Status:      http.StatusForbidden,
+		Description: "The account balance <b>does not</b> cover the cost of the operation.",
+	})
+
+	testCases := map[string]struct {
+		InputMethod         string
+		InputPath           string
+		InputAccept         string
+		ExpectedStatus      int
+		ExpectedContentType string
+		ExpectedContains    []string
+	}{
+		"HTML": {
+			InputPath:           "/probs/out-of-credit",
+			InputAccept:         "text/html,application/xhtml+xml,*/*;q=0.8",
+			ExpectedStatus:      http.StatusOK,
+			ExpectedContentType: "text/html; charset=utf-8",
+			ExpectedContains: []string{
+				"<h1>You do not have enough credit.</h1>",
+				"403 Forbidden",
+				"&lt;b&gt;does not&lt;/b&gt;",
+				"&#34;type&#34;:&#34;https://example.com/probs/out-of-credit&#34;",
+				"&lt;problem xmlns=&#34;urn:ietf:rfc:7807&#34;&gt;",
+			},
+		},
+		"JSON": {
+			InputPath:           "/probs/out-of-credit",
+			InputAccept:         "application/json",
+			ExpectedStatus:      http.StatusOK,
+			ExpectedContentType: "application/json",
+			ExpectedContains: []string{
+				compactJson(`
+					{
+						"type": "https://example.com/probs/out-of-credit",
+						"title": "You do not have enough credit.",
+						"status": 403,
+						"description": "The account balance \u003cb\u003edoes not\u003c/b\u003e cover the cost of the operation.",
+						"example": {
+							"type": "https://example.com/probs/out-of-credit",
+							"status": 403,
+							"title": "You do not have enough credit.",
+							"detail": "",
+							"instance": ""
+						}
+					}
+				`),
+			},
+		},
+		"Unknown Type": {
+			InputPath:           "/probs/unknown",
+			ExpectedStatus:      http.StatusNotFound,
+			ExpectedContentType: MediaTypeProblemJSON,
+		},
+		"Method Not Allowed": {
+			InputMethod:         http.MethodPost,
+			InputPath:           "/probs/out-of-credit",
+			ExpectedStatus:      http.StatusMethodNotAllowed,
+			ExpectedContentType: MediaTypeProblemJSON,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			req := httptest.NewRequest(tc.InputMethod, tc.InputPath, nil)
+			req.Header.Set("Accept", tc.InputAccept)
+
+			catalog.DocsHandler().ServeHTTP(recorder, req)
+
+			if recorder.Code != tc.ExpectedStatus {
+				t.Errorf("expected %d, got %d", tc.ExpectedStatus, recorder.Code)
+			}
+
+			if contentType := recorder.Header().Get("Content-Type"); contentType != tc.ExpectedContentType {
+				t.Errorf("expected %s, got %s", tc.ExpectedContentType, contentType)
+			}
+
+			body := recorder.Body.String()
+			for _, s := range tc.ExpectedContains {
+				if !strings.Contains(body, s) {
+					t.Errorf("expected body to contain %s, got %s", s, body)
+				}
+			}
+		})
+	}
+}
+
+func TestServeInstance(t *testing.T) {
+	uuidRegexp := regexp.MustCompile(`^urn:uuid:[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+	testCases := map[string]struct {
+		InputProblem     *RegisteredProblem
+		InputRequestID   string
+		InputOptions     []Option
+		ExpectedInstance *regexp.Regexp
+		ExpectedHeader   bool
+	}{
+		"No Options": {
+			InputProblem:     NewRegistered(http.StatusBadRequest, "test"),
+			ExpectedInstance: regexp.MustCompile(`^$`),
+		},
+		"UUID": {
+			InputProblem:     NewRegistered(http.StatusBadRequest, "test"),
+			InputOptions:     []Option{WithInstance(UUIDInstance), WithInstanceHeader("X-Problem-Instance")},
+			ExpectedInstance: uuidRegexp,
+			ExpectedHeader:   true,
+		},
+		"Request ID": {
+			InputProblem:     NewRegistered(http.StatusBadRequest, "test"),
+			InputRequestID:   "abc123",
+			InputOptions:     []Option{WithInstance(RequestIDInstance("X-Request-Id")), WithInstanceHeader("X-Request-Id")},
+			ExpectedInstance: regexp.MustCompile(`^abc123$`),
+			ExpectedHeader:   true,
+		},
+		"Request ID Fallback": {
+			InputProblem:     NewRegistered(http.StatusBadRequest, "test"),
+			InputOptions:     []Option{WithInstance(FirstInstance(RequestIDInstance("X-Request-Id"), UUIDInstance))},
+			ExpectedInstance: uuidRegexp,
+		},
+		"Path": {
+			InputProblem:     NewRegistered(http.StatusBadRequest, "test"),
+			InputOptions:     []Option{WithInstance(PathInstance)},
+			ExpectedInstance: regexp.MustCompile(`^/accounts/12345$`),
+		},
+		"Already Set": {
+			InputProblem: &RegisteredProblem{
+				Type:     "about:blank",
+				Status:   http.StatusBadRequest,
+				Instance: "/already/set",
+			},
+			InputOptions:     []Option{WithInstance(UUIDInstance), WithInstanceHeader("X-Problem-Instance")},
+			ExpectedInstance: regexp.MustCompile(`^/already/set$`),
+			ExpectedHeader:   true,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			originalInstance := tc.InputProblem.Instance
+
+			recorder := httptest.NewRecorder()
+			req := httptest.NewRequest("", "/accounts/12345", nil)
+			if tc.InputRequestID != "" {
+				req.Header.Set("X-Request-Id", tc.InputRequestID)
+			}
+
+			Serve(tc.InputProblem, tc.InputOptions...).ServeHTTP(recorder, req)
+
+			if tc.InputProblem.Instance != originalInstance {
+				t.Errorf("expected input problem not to be modified, got instance %s", tc.InputProblem.Instance)
+			}
+
+			res := recorder.Result()
+
+			p, err := ParseResponse(res)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !tc.ExpectedInstance.MatchString(p.GetInstance()) {
+				t.Errorf("expected instance to match %s, got %s", tc.ExpectedInstance, p.GetInstance())
+			}
+
+			header := res.Header.Get("X-Problem-Instance") + res.Header.Get("X-Request-Id")
+			if tc.ExpectedHeader && header != p.GetInstance() {
+				t.Errorf("expected header %s, got %s", p.GetInstance(), header)
+			} else if !tc.ExpectedHeader && header != "" {
+				t.Errorf("expected no header, got %s", header)
+			}
+		})
+	}
+}
+
+func TestServeInstanceMapProblem(t *testing.T) {
+	p := NewMap(http.StatusBadRequest, "test")
+
+	recorder := httptest.NewRecorder()
+	Serve(p, WithInstance(PathInstance)).ServeHTTP(recorder, httptest.NewRequest("", "/test", nil))
+
+	if _, ok := p["instance"]; ok {
+		t.Errorf("expected input problem not to be modified, got instance %v", p["instance"])
+	}
+
+	outProblem, err := ParseResponse(recorder.Result())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if outProblem.GetInstance() != "/test" {
+		t.Errorf("expected /test, got %s", outProblem.GetInstance())
+	}
+}
+
+func TestProblemAsError(t *testing.T) {
+	errCause := errors.New("connection refused")
+
+	serviceLayer := func() error {
+		return NewRegistered(http.StatusServiceUnavailable, "try again later").Wrap(errCause)
+	}
+
+	err := fmt.Errorf("handler: %w", serviceLayer())
+
+	if !errors.Is(err, errCause) {
+		t.Errorf("expected error chain to contain %v", errCause)
+	}
+
+	var p Problem
+	if !errors.As(err, &p) {
+		t.Fatalf("expected error chain to contain a Problem")
+	}
+
+	expectedMessage := "503 Service Unavailable: try again later: connection refused"
+	if p.Error() != expectedMessage {
+		t.Errorf("expected %s, got %s", expectedMessage, p.Error())
+	}
+
+	var rp *RegisteredProblem
+	if !errors.As(err, &rp) || rp.Unwrap() != errCause {
+		t.Errorf("expected *RegisteredProblem wrapping %v", errCause)
+	}
+
+	b, jsonErr := json.Marshal(p)
+	if jsonErr != nil {
+		t.Fatal(jsonErr)
+	}
+	if strings.Contains(string(b), errCause.Error()) {
+		t.Errorf("expected cause not to be marshaled, got %s", b)
+	}
+
+	var mapper Mapper
+	if mapped := mapper.FromError(err); mapped != p {
+		t.Errorf("expected mapper to return the wrapped problem, got %+v", mapped)
+	}
+
+	// Problems returned by ParseResponse can be propagated as errors
+	recorder := httptest.NewRecorder()
+	ServeJSON(p).ServeHTTP(recorder, httptest.NewRequest("", "/", nil))
+
+	parsed, parseErr := ParseResponse(recorder.Result())
+	if parseErr != nil {
+		t.Fatal(parseErr)
+	}
+
+	err = fmt.Errorf("calling remote: %w", parsed)
+
+	expectedMessage = "calling remote: 503 Service Unavailable: try again later"
+	if err.Error() != expectedMessage {
+		t.Errorf("expected %s, got %s", expectedMessage, err.Error())
+	}
+
+	if !errors.As(err, &p) || p.GetStatus() != http.StatusServiceUnavailable {
+		t.Errorf("expected error chain to contain the parsed problem")
+	}
+}
+
+func TestValidationProblem(t *testing.T) {
+	p := NewValidation(http.StatusUnprocessableEntity, "Your request is not valid.")
+
+	if p.HasErrors() {
+		t.Errorf("expected no errors")
+	}
+
+	p.AddPointer("#/age", "must be a positive integer").
+		AddParameter("page", "must be a number").
+		AddHeader("If-Match", "must be an entity tag")
+
+	expectedJSON := compactJson(`
+		{
+			"type": "about:blank",
+			"status": 422,
+			"title": "Unprocessable Entity",
+			"detail": "Your request is not valid.",
+			"instance": "",
+			"errors": [
+				{
+					"detail": "must be a positive integer",
+					"pointer": "#/age"
+				},
+				{
+					"detail": "must be a number",
+					"parameter": "page"
+				},
+				{
+					"detail": "must be an entity tag",
+					"header": "If-Match"
+				}
+			]
+		}
+	`)
+
+	b, err := json.Marshal(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != expectedJSON {
+		t.Errorf("expected %s, got %s", expectedJSON, b)
+	}
+
+	b, err = json.Marshal(NewValidation(http.StatusBadRequest, ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), `"errors":[]`) {
+		t.Errorf("expected empty errors array, got %s", b)
+	}
+
+	expectedXML := `<problem xmlns="urn:ietf:rfc:7807">` +
+		`<type>about:blank</type><status>422</status><title>Unprocessable Entity</title>` +
+		`<detail>Your request is not valid.</detail><instance></instance>` +
+		`<errors>` +
+		`<i><detail>must be a positive integer</detail><pointer>#/age</pointer></i>` +
+		`<i><detail>must be a number</detail><parameter>page</parameter></i>` +
+		`<i><detail>must be an entity tag</detail><header>If-Match</header></i>` +
+		`</errors>` +
+		`</problem>`
+
+	b, err = xml.Marshal(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != expectedXML {
+		t.Errorf("expected %s, got %s", expectedXML, b)
+	}
+
+	for _, serve := range []func(Problem, ...Option) http.Handler{ServeJSON, ServeXML} {
+		recorder := httptest.NewRecorder()
+		serve(p).ServeHTTP(recorder, httptest.NewRequest("", "/", nil))
+
+		outProblem := &ValidationProblem{}
+		err = ParseResponseCustom(recorder.Result(), outProblem)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !equalProblems(outProblem, p) {
+			t.Errorf("expected %+v, got %+v", p, outProblem)
+		}
+
+		if len(outProblem.Errors) != len(p.Errors) {
+			t.Fatalf("expected %d errors, got %d", len(p.Errors), len(outProblem.Errors))
+		}
+		for i := range p.Errors {
+			if outProblem.Errors[i] != p.Errors[i] {
+				t.Errorf("expected %+v, got %+v", p.Errors[i], outProblem.Errors[i])
+			}
+		}
+	}
+}
+
+func TestServeTranslations(t *testing.T) {
+	fsys := fstest.MapFS{
+		"en.json": &fstest.MapFile{Data: []byte(`{
+			"balance.insufficient": "Your current balance is not enough.",
+			"thing.missing": "Thing missing"
+		}`)},
+		"es.json": &fstest.MapFile{Data: []byte(`{
+			"https://example.com/probs/out-of-credit": "No tienes suficiente crédito.",
+			"Not Found": "No encontrado",
+			"balance.insufficient": "Tu saldo actual es insuficiente."
+		}`)},
+		"es-MX.json": &fstest.MapFile{Data: []byte(`{
+			"balance.insufficient": "Tu saldo actual no alcanza."
+		}`)},
+		"README.md": &fstest.MapFile{Data: []byte(`Not a translation file`)},
+	}
+
+	translations, err := LoadTranslations(fsys, "en")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	outOfCredit := Type{
+		URI:    "https://example.com/probs/out-of-credit",
+		Title:  "You do not have enough credit.",
+		Status: http.StatusForbidden,
+	}
+
+	testCases := map[string]struct {
+		InputProblem     Problem
+		InputLanguage    string
+		ExpectedLanguage string
+		ExpectedTitle    string
+		ExpectedDetail   string
+	}{
+		"Default Language": {
+			InputProblem:     outOfCredit.New("balance.insufficient"),
+			ExpectedLanguage: "en",
+			ExpectedTitle:    "You do not have enough credit.",
+			ExpectedDetail:   "Your current balance is not enough.",
+		},
+		"Exact Language": {
+			InputProblem:     outOfCredit.New("balance.insufficient"),
+			InputLanguage:    "es-MX",
+			ExpectedLanguage: "es, es-MX",
+			ExpectedTitle:    "No tienes suficiente crédito.",
+			ExpectedDetail:   "Tu saldo actual no alcanza.",
+		},
+		"Parent Language": {
+			InputProblem:     outOfCredit.New("balance.insufficient"),
+			InputLanguage:    "es-AR, en;q=0.5",
+			ExpectedLanguage: "es",
+			ExpectedTitle:    "No tienes suficiente crédito.",
+			ExpectedDetail:   "Tu saldo actual es insuficiente.",
+		},
+		"Q-Values": {
+			InputProblem:     outOfCredit.New("balance.insufficient"),
+			InputLanguage:    "es;q=0.5, en",
+			ExpectedLanguage: "en",
+			ExpectedTitle:    "You do not have enough credit.",
+			ExpectedDetail:   "Your current balance is not enough.",
+		},
+		"Unavailable Language": {
+			InputProblem:     outOfCredit.New("balance.insufficient"),
+			InputLanguage:    "fr",
+			ExpectedLanguage: "en",
+			ExpectedTitle:    "You do not have enough credit.",
+			ExpectedDetail:   "Your current balance is not enough.",
+		},
+		"Status Text Title": {
+			InputProblem:     NewMap(http.StatusNotFound, ""),
+			InputLanguage:    "es",
+			ExpectedLanguage: "es",
+			ExpectedTitle:    "No encontrado",
+			ExpectedDetail:   "",
+		},
+		"Untranslated Detail": {
+			InputProblem:     NewMap(http.StatusNotFound, "no translation"),
+			InputLanguage:    "es",
+			ExpectedLanguage: "es, en",
+			ExpectedTitle:    "No encontrado",
+			ExpectedDetail:   "no translation",
+		},
+		"Parent Bundle": {
+			InputProblem:     NewMap(http.StatusNotFound, ""),
+			InputLanguage:    "es-MX",
+			ExpectedLanguage: "es",
+			ExpectedTitle:    "No encontrado",
+			ExpectedDetail:   "",
+		},
+		"Partial Bundle": {
+			InputProblem:     NewMap(http.StatusNotFound, "thing.missing"),
+			InputLanguage:    "es",
+			ExpectedLanguage: "es, en",
+			ExpectedTitle:    "No encontrado",
+			ExpectedDetail:   "Thing missing",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			originalDetail := tc.InputProblem.GetDetail()
+
+			recorder := httptest.NewRecorder()
+			req := httptest.NewRequest("", "/", nil)
+			if tc.InputLanguage != "" {
+				req.Header.Set("Accept-Language", tc.InputLanguage)
+			}
+
+			Serve(tc.InputProblem, WithTranslations(translations)).ServeHTTP(recorder, req)
+
+			if tc.InputProblem.GetDetail() != originalDetail {
+				t.Errorf("expected input problem not to be modified")
+			}
+
+			res := recorder.Result()
+
+			if lang := res.Header.Get("Content-Language"); lang != tc.ExpectedLanguage {
+				t.Errorf("expected Content-Language %s, got %s", tc.ExpectedLanguage, lang)
+			}
+
+			p, err := ParseResponse(res)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if p.GetTitle() != tc.ExpectedTitle {
+				t.Errorf("expected %s, got %s", tc.ExpectedTitle, p.GetTitle())
+			}
+
+			if p.GetDetail() != tc.ExpectedDetail {
+				t.Errorf("expected %s, got %s", tc.ExpectedDetail, p.GetDetail())
+			}
+		})
+	}
+}
+
+func TestLoadTranslationsBadFile(t *testing.T) {
+	fsys := fstest.MapFS{
+		"en.json": &fstest.MapFile{Data: []byte(`{"key": 123}`)},
+	}
+
+	if _, err := LoadTranslations(fsys, "en"); err == nil {
+		t.Errorf("expected error to be non-nil, got <nil>")
+	}
+}
+
+func TestServeHTML(t *testing.T) {
+	p := &testFieldProblem{
+		RegisteredProblem: *NewRegistered(http.StatusBadRequest, "<script>alert(1)</script>"),
+		Field:             "<b>name</b>",
+	}
+
+	m := NewMap(http.StatusBadRequest, "test")
+	m["balance"] = 30
+	m["accounts"] = []string{"/account/12345", "/account/67890"}
+
+	testCases := map[string]struct {
+		InputProblem     Problem
+		InputOptions     []Option
+		ExpectedContains []string
+		ExpectedMissing  []string
+	}{
+		"Escaped": {
+			InputProblem: p,
+			ExpectedContains: []string{
+				"<title>400 Bad Request</title>",
+				"<p>&lt;script&gt;alert(1)&lt;/script&gt;</p>",
+				"<dt>field</dt>\n<dd>&lt;b&gt;name&lt;/b&gt;</dd>",
+			},
+			ExpectedMissing: []string{"<script>", "<b>", "<dt>Instance</dt>"},
+		},
+		"MapProblem Extensions": {
+			InputProblem: m,
+			ExpectedContains: []string{
+				"<dt>accounts</dt>\n<dd>[&#34;/account/12345&#34;,&#34;/account/67890&#34;]</dd>",
+				"<dt>balance</dt>\n<dd>30</dd>",
+			},
+		},
+		"Custom Template": {
+			InputProblem: p,
+			InputOptions: []Option{WithHTMLTemplate(template.Must(template.New("").Parse(
+				`<h1>{{.Status}}</h1>{{range .Extensions}}{{.Name}}={{.Value}}{{end}}`,
+			)))},
+			ExpectedContains: []string{"<h1>400</h1>field=&lt;b&gt;name&lt;/b&gt;"},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			req := httptest.NewRequest("", "/", nil)
+			req.Header.Set("Accept", "text/html")
+
+			Serve(tc.InputProblem, tc.InputOptions...).ServeHTTP(recorder, req)
+
+			if recorder.Code != http.StatusBadRequest {
+				t.Errorf("expected %d, got %d", http.StatusBadRequest, recorder.Code)
+			}
+
+			if nosniff := recorder.Header().Get("X-Content-Type-Options"); nosniff != "nosniff" {
+				t.Errorf("expected nosniff, got %s", nosniff)
+			}
+
+			body := recorder.Body.String()
+			for _, s := range tc.ExpectedContains {
+				if !strings.Contains(body, s) {
+					t.Errorf("expected body to contain %s, got %s", s, body)
+				}
+			}
+			for _, s := range tc.ExpectedMissing {
+				if strings.Contains(body, s) {
+					t.Errorf("expected body not to contain %s, got %s", s, body)
+				}
+			}
+		})
+	}
+}
+
+func TestFormat(t *testing.T) {
+	m := NewMap(http.StatusForbidden, "Your current balance is 30, but that costs 50.")
+	m["type"] = "https://example.com/probs/out-of-credit"
+	m["title"] = "You do not have enough credit."
+	m["instance"] = "/account/12345/msgs/abc"
+	m["currency"] = "EUR"
+	m["balance"] = 30
+	m["note"] = "has spaces"
+	m["accounts"] = []string{"/account/12345"}
+
+	testCases := map[string]struct {
+		InputProblem Problem
+		Expected     string
+	}{
+		"Registered": {
+			InputProblem: NewRegistered(http.StatusBadRequest, "test"),
+			Expected:     "400 Bad Request: test (about:blank)",
+		},
+		"No Detail": {
+			InputProblem: &RegisteredProblem{Type: "about:blank", Status: http.StatusNotFound, Title: "Not Found", Instance: "/x"},
+			Expected:     "404 Not Found (about:blank, /x)",
+		},
+		"Extensions Sorted": {
+			InputProblem: m,
+			Expected: `403 You do not have enough credit.: Your current balance is 30, but that costs 50.` +
+				` (https://example.com/probs/out-of-credit, /account/12345/msgs/abc)` +
+				` accounts=["/account/12345"] balance=30 currency=EUR note="has spaces"`,
+		},
+		"Embedded": {
+			InputProblem: &Embed{
+				RegisteredProblem: *NewRegistered(http.StatusBadRequest, "test"),
+				Extension1:        "",
+				Extension2:        "e2",
+			},
+			Expected: `400 Bad Request: test (about:blank) extension1="" extension2=e2`,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			if s := Format(tc.InputProblem); s != tc.Expected {
+				t.Errorf("expected %s, got %s", tc.Expected, s)
+			}
+
+			recorder := httptest.NewRecorder()
+			req := httptest.NewRequest("", "/", nil)
+			req.Header.Set("Accept", "text/plain")
+
+			Serve(tc.InputProblem).ServeHTTP(recorder, req)
+
+			if contentType := recorder.Header().Get("Content-Type"); contentType != "text/plain; charset=utf-8" {
+				t.Errorf("expected text/plain; charset=utf-8, got %s", contentType)
+			}
+
+			if body := recorder.Body.String(); body != tc.Expected+"\n" {
+				t.Errorf("expected %s, got %s", tc.Expected, body)
+			}
+		})
+	}
+}
+
+func TestFormatVerbs(t *testing.T) {
+	m := MapProblem{"status": 409, "title": "Conflict", "version": 3}
+
+	testCases := map[string]struct {
+		InputVerb string
+		Expected  string
+	}{
+		"Plus": {
+			InputVerb: "%+v",
+			Expected:  "409 Conflict version=3",
+		},
+		"Value": {
+			InputVerb: "%v",
+			Expected:  m.Error(),
+		},
+		"String Width": {
+			InputVerb: "%15s",
+			Expected:  fmt.Sprintf("%15s", m.Error()),
+		},
+		"Quoted": {
+			InputVerb: "%q",
+			Expected:  strconv.Quote(m.Error()),
+		},
+		"Go Syntax": {
+			InputVerb: "%#v",
+			Expected:  fmt.Sprintf("%#v", map[string]any(m)),
+		},
+		"Other Verb": {
+			InputVerb: "%d",
+			Expected:  "map[%!d(string=status):409 %!d(string=title):%!d(string=Conflict) %!d(string=version):3]",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			if s := fmt.Sprintf(tc.InputVerb, m); s != tc.Expected {
+				t.Errorf("expected %s, got %s", tc.Expected, s)
+			}
+		})
+	}
+
+	// Struct problems keep the default formatting
+	p := NewRegistered(http.StatusNotFound, "x")
+	if s, expected := fmt.Sprintf("%#v", p), fmt.Sprintf("%#v", *p); !strings.HasSuffix(s, expected) {
+		t.Errorf("expected %s, got %s", expected, s)
+	}
+}
+
+func TestServeRedaction(t *testing.T) {
+	internal := &Embed{
+		RegisteredProblem: *NewRegistered(http.StatusInternalServerError, "pq: connection refused"),
+		Extension1:        "secret",
+		Extension2:        "safe",
+	}
+
+	m := NewMap(http.StatusServiceUnavailable, "upstream down")
+	m["host"] = "db-1.internal"
+	m["trace_id"] = "abc"
+
+	testCases := map[string]struct {
+		InputProblem       Problem
+		InputRedaction     Redaction
+		InputAccept        string
+		ExpectedDetail     string
+		ExpectedContains   []string
+		ExpectedMissing    []string
+		ExpectedHookDetail string
+	}{
+		"JSON": {
+			InputProblem:       internal,
+			InputRedaction:     Redaction{Detail: "Please try again later.", AllowedExtensions: []string{"extension2"}},
+			InputAccept:        MediaTypeProblemJSON,
+			ExpectedDetail:     "Please try again later.",
+			ExpectedContains:   []string{`"extension2":"safe"`, `"instance":""`},
+			ExpectedMissing:    []string{"extension1", "secret", "connection refused"},
+			ExpectedHookDetail: "pq: connection refused",
+		},
+		"XML": {
+			InputProblem:       internal,
+			InputRedaction:     Redaction{AllowedExtensions: []string{"extension2"}},
+			InputAccept:        MediaTypeProblemXML,
+			ExpectedDetail:     "",
+			ExpectedContains:   []string{`<problem xmlns="urn:ietf:rfc:7807"><type>about:blank</type>`, "<extension2>safe</extension2></problem>"},
+			ExpectedMissing:    []string{"extension1", "secret", "connection refused"},
+			ExpectedHookDetail: "pq: connection refused",
+		},
+		"MapProblem": {
+			InputProblem:       m,
+			InputRedaction:     Redaction{AllowedExtensions: []string{"trace_id"}},
+			InputAccept:        MediaTypeProblemJSON,
+			ExpectedDetail:     "",
+			ExpectedContains:   []string{`"trace_id":"abc"`},
+			ExpectedMissing:    []string{"host", "upstream down"},
+			ExpectedHookDetail: "upstream down",
+		},
+		"Text": {
+			InputProblem:       m,
+			InputRedaction:     Redaction{},
+			InputAccept:        "text/plain",
+			ExpectedContains:   []string{"503 Service Unavailable (about:blank)\n"},
+			ExpectedMissing:    []string{"host", "trace_id", "upstream down"},
+			ExpectedHookDetail: "upstream down",
+		},
+		"Status Class Not Redacted": {
+			InputProblem:       internal,
+			InputRedaction:     Redaction{StatusClasses: []int{4}},
+			InputAccept:        MediaTypeProblemJSON,
+			ExpectedDetail:     "pq: connection refused",
+			ExpectedContains:   []string{`"extension1":"secret"`},
+			ExpectedHookDetail: "pq: connection refused",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			var hookDetail string
+			hook := WithHook(func(r *http.Request, p Problem) {
+				hookDetail = p.GetDetail()
+			})
+
+			recorder := httptest.NewRecorder()
+			req := httptest.NewRequest("", "/", nil)
+			req.Header.Set("Accept", tc.InputAccept)
+
+			Serve(tc.InputProblem, WithRedaction(tc.InputRedaction), hook).ServeHTTP(recorder, req)
+
+			if hookDetail != tc.ExpectedHookDetail {
+				t.Errorf("expected hook detail %s, got %s", tc.ExpectedHookDetail, hookDetail)
+			}
+
+			if tc.InputProblem.GetDetail() != tc.ExpectedHookDetail {
+				t.Errorf("expected input problem not to be modified")
+			}
+
+			body := recorder.Body.String()
+			for _, s := range tc.ExpectedContains {
+				if !strings.Contains(body, s) {
+					t.Errorf("expected body to contain %s, got %s", s, body)
+				}
+			}
+			for _, s := range tc.ExpectedMissing {
+				if strings.Contains(body, s) {
+					t.Errorf("expected body not to contain %s, got %s", s, body)
+				}
+			}
+
+			if tc.InputAccept == "text/plain" {
+				return
+			}
+
+			p, err := ParseResponse(recorder.Result())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if p.GetDetail() != tc.ExpectedDetail {
+				t.Errorf("expected %s, got %s", tc.ExpectedDetail, p.GetDetail())
+			}
+		})
+	}
+}
+
+func TestServeDebug(t *testing.T) {
+	SetCaptureStacks(true)
+	defer SetCaptureStacks(false)
+
+	errRoot := errors.New("connection refused")
+	p := NewRegistered(http.StatusServiceUnavailable, "try again later").
+		Wrap(fmt.Errorf("querying users: %w", errRoot))
+
+	SetCaptureStacks(false)
+	withoutStack := NewRegistered(http.StatusBadRequest, "test")
+	SetCaptureStacks(true)
+
+	type debugProblem struct {
+		RegisteredProblem
+		Stack []string `json:"stack" xml:"stack>i"`
+		Cause []string `json:"cause" xml:"cause>i"`
+	}
+
+	testCases := map[string]struct {
+		InputProblem  Problem
+		InputOptions  []Option
+		InputAccept   string
+		ExpectedStack string
+		ExpectedCause []string
+	}{
+		"JSON": {
+			InputProblem:  p,
+			InputOptions:  []Option{WithDebug()},
+			InputAccept:   MediaTypeProblemJSON,
+			ExpectedStack: "TestServeDebug",
+			ExpectedCause: []string{"querying users: connection refused", "connection refused"},
+		},
+		"XML": {
+			InputProblem:  p,
+			InputOptions:  []Option{WithDebug()},
+			InputAccept:   MediaTypeProblemXML,
+			ExpectedStack: "TestServeDebug",
+			ExpectedCause: []string{"querying users: connection refused", "connection refused"},
+		},
+		"Validation Constructor": {
+			InputProblem:  NewValidation(http.StatusUnprocessableEntity, "invalid"),
+			InputOptions:  []Option{WithDebug()},
+			InputAccept:   MediaTypeProblemJSON,
+			ExpectedStack: "TestServeDebug",
+		},
+		"Unauthorized Constructor": {
+			InputProblem:  NewUnauthorized("", `Bearer realm="example"`),
+			InputOptions:  []Option{WithDebug()},
+			InputAccept:   MediaTypeProblemJSON,
+			ExpectedStack: "TestServeDebug",
+		},
+		"Method Not Allowed Constructor": {
+			InputProblem:  NewMethodNotAllowed("", http.MethodGet),
+			InputOptions:  []Option{WithDebug()},
+			InputAccept:   MediaTypeProblemJSON,
+			ExpectedStack: "TestServeDebug",
+		},
+		"Typed Constructor": {
+			InputProblem:  NewTyped(http.StatusForbidden, "", testOutOfCredit{}),
+			InputOptions:  []Option{WithDebug()},
+			InputAccept:   MediaTypeProblemJSON,
+			ExpectedStack: "TestServeDebug",
+		},
+		"Disabled": {
+			InputProblem: p,
+			InputAccept:  MediaTypeProblemJSON,
+		},
+		"Not Captured": {
+			InputProblem: withoutStack,
+			InputOptions: []Option{WithDebug()},
+			InputAccept:  MediaTypeProblemXML,
+		},
+		"Redacted": {
+			InputProblem: p,
+			InputOptions: []Option{WithDebug(), WithRedaction(Redaction{})},
+			InputAccept:  MediaTypeProblemJSON,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			req := httptest.NewRequest("", "/", nil)
+			req.Header.Set("Accept", tc.InputAccept)
+
+			Serve(tc.InputProblem, tc.InputOptions...).ServeHTTP(recorder, req)
+
+			body := recorder.Body.String()
+
+			outProblem := &debugProblem{}
+			err := ParseResponseCustom(recorder.Result(), outProblem)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tc.ExpectedStack == "" && len(outProblem.Stack) > 0 {
+				t.Errorf("expected no stack, got %v", outProblem.Stack)
+			} else if tc.ExpectedStack != "" && (len(outProblem.Stack) == 0 || !strings.Contains(outProblem.Stack[0], tc.ExpectedStack)) {
+				t.Errorf("expected stack starting at %s, got %v", tc.ExpectedStack, outProblem.Stack)
+			}
+
+			if !slices.Equal(outProblem.Cause, tc.ExpectedCause) {
+				t.Errorf("expected %v, got %v", tc.ExpectedCause, outProblem.Cause)
+			}
+
+			if tc.InputAccept == MediaTypeProblemXML && tc.ExpectedCause != nil &&
+				!strings.Contains(body, "<cause><i>querying users: connection refused</i><i>connection refused</i></cause></problem>") {
+				t.Errorf("expected Appendix B arrays, got %s", body)
+			}
+		})
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	at := time.Now().Add(time.Hour).Truncate(time.Second)
+
+	m := NewMap(http.StatusTooManyRequests, "slow down")
+	m["retry_after"] = 30
+
+	testCases := map[string]struct {
+		InputProblem     Problem
+		InputOptions     []Option
+		ExpectedHeader   string
+		ExpectedMember   string
+		ExpectedDuration time.Duration
+	}{
+		"Delay": {
+			InputProblem:     NewRegistered(http.StatusTooManyRequests, "slow down").SetRetryAfter(1500 * time.Millisecond),
+			ExpectedHeader:   "2",
+			ExpectedDuration: 2 * time.Second,
+		},
+		"Delay With Member": {
+			InputProblem:     NewRegistered(http.StatusTooManyRequests, "slow down").SetRetryAfter(2 * time.Minute),
+			InputOptions:     []Option{WithRetryAfterMember()},
+			ExpectedHeader:   "120",
+			ExpectedMember:   `"retry_after":120`,
+			ExpectedDuration: 2 * time.Minute,
+		},
+		"Absolute": {
+			InputProblem:     NewRegistered(http.StatusServiceUnavailable, "maintenance").SetRetryAt(at),
+			ExpectedHeader:   at.UTC().Format(http.TimeFormat),
+			ExpectedDuration: time.Hour,
+		},
+		"MapProblem": {
+			InputProblem:     m,
+			ExpectedHeader:   "30",
+			ExpectedMember:   `"retry_after":30`,
+			ExpectedDuration: 30 * time.Second,
+		},
+		"None": {
+			InputProblem: NewRegistered(http.StatusTooManyRequests, "slow down"),
+			InputOptions: []Option{WithRetryAfterMember()},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+
+			Serve(tc.InputProblem, tc.InputOptions...).ServeHTTP(recorder, httptest.NewRequest("", "/", nil))
+
+			if header := recorder.Header().Get("Retry-After"); header != tc.ExpectedHeader {
+				t.Errorf("expected Retry-After %s, got %s", tc.ExpectedHeader, header)
+			}
+
+			body := recorder.Body.String()
+			if tc.ExpectedMember != "" && !strings.Contains(body, tc.ExpectedMember) {
+				t.Errorf("expected body to contain %s, got %s", tc.ExpectedMember, body)
+			} else if tc.ExpectedMember == "" && strings.Contains(body, "retry_after") {
+				t.Errorf("expected body not to contain retry_after, got %s", body)
+			}
+
+			for _, outProblem := range []Problem{&MapProblem{}, &RegisteredProblem{}} {
+				res := responseFactory(recorder.Code, recorder.Header().Get("Content-Type"), body)
+				res.Header.Set("Retry-After", recorder.Header().Get("Retry-After"))
+
+				err := ParseResponseCustom(res, outProblem)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				d, ok := RetryAfter(outProblem)
+				if _, isMap := outProblem.(*MapProblem); isMap && !ok {
+					// The header is not added to the members
+					d, ok = ParseRetryAfter(res)
+				}
+				if ok != (tc.ExpectedDuration > 0) {
+					t.Fatalf("expected retry to be present: %v, got %v", tc.ExpectedDuration > 0, ok)
+				}
+
+				// Absolute times are compared with some tolerance
+				if diff := tc.ExpectedDuration - d; diff < 0 || diff > 2*time.Second {
+					t.Errorf("expected %s, got %s", tc.ExpectedDuration, d)
+				}
+			}
+		})
+	}
+}
+
+func TestRetryAfterParsedMapProblem(t *testing.T) {
+	at := time.Now().Add(time.Hour)
+
+	for header, expected := range map[string]time.Duration{
+		"120":                            2 * time.Minute,
+		at.UTC().Format(http.TimeFormat): time.Hour,
+	} {
+		t.Run(header, func(t *testing.T) {
+			res := responseFactory(http.StatusServiceUnavailable, MediaTypeProblemJSON, `{"type":"about:blank","status":503}`)
+			res.Header.Set("Retry-After", header)
+
+			p, err := ParseResponse(res)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if _, ok := (*p.(*MapProblem))["retry_after"]; ok {
+				t.Errorf("expected no retry_after member, got %v", p)
+			}
+
+			if _, ok := RetryAfter(p); ok {
+				t.Errorf("expected no retry delay in the members")
+			}
+
+			d, ok := ParseRetryAfter(res)
+			if !ok {
+				t.Fatalf("expected retry to be present")
+			}
+			if diff := expected - d; diff < 0 || diff > 2*time.Second {
+				t.Errorf("expected %s, got %s", expected, d)
+			}
+
+			recorder := httptest.NewRecorder()
+			ServeJSON(p).ServeHTTP(recorder, httptest.NewRequest("", "/", nil))
+
+			if body := recorder.Body.String(); strings.Contains(body, "retry_after") {
+				t.Errorf("expected re-served body not to contain retry_after, got %s", body)
+			}
+		})
+	}
+}
+
+type testHeaderProblem struct {
+	MapProblem
+}
+
+func (p testHeaderProblem) Header() http.Header {
+	return http.Header{"Link": []string{`<https://example.com/docs>; rel="help"`}}
+}
+
+func TestServeHeaders(t *testing.T) {
+	testCases := map[string]struct {
+		InputProblem    Problem
+		InputHeader     http.Header
+		ExpectedHeaders http.Header
+	}{
+		"Unauthorized": {
+			InputProblem: NewUnauthorized("missing token", `Bearer realm="api"`, `Basic realm="api"`),
+			ExpectedHeaders: http.Header{
+				"Www-Authenticate": []string{`Bearer realm="api"`, `Basic realm="api"`},
+			},
+		},
+		"Method Not Allowed": {
+			InputProblem: NewMethodNotAllowed("", http.MethodGet, http.MethodHead),
+			ExpectedHeaders: http.Header{
+				"Allow": []string{"GET, HEAD"},
+			},
+		},
+		"Custom Headers Cannot Override Content Headers": {
+			InputProblem: NewRegistered(http.StatusConflict, "test").
+				SetHeader("Location", "/resources/1").
+				SetHeader("Content-Type", "text/plain"),
+			ExpectedHeaders: http.Header{
+				"Location":     []string{"/resources/1"},
+				"Content-Type": []string{MediaTypeProblemJSON},
+			},
+		},
+		"Custom HeaderProvider": {
+			InputProblem: testHeaderProblem{NewMap(http.StatusBadRequest, "test")},
+			ExpectedHeaders: http.Header{
+				"Link": []string{`<https://example.com/docs>; rel="help"`},
+			},
+		},
+		"List Headers Merged": {
+			InputProblem: NewRegistered(http.StatusBadRequest, "test").
+				SetHeader("Vary", "Origin").
+				SetHeader("Link", `<https://example.com/docs>; rel="help"`).
+				SetHeader("Cache-Control", "no-store"),
+			InputHeader: http.Header{
+				"Vary":          []string{"Accept-Encoding"},
+				"Link":          []string{`<https://example.com/a>; rel="next"`},
+				"Cache-Control": []string{"max-age=60"},
+			},
+			ExpectedHeaders: http.Header{
+				"Vary":          []string{"Accept-Encoding", "Accept", "Origin"},
+				"Link":          []string{`<https://example.com/a>; rel="next"`, `<https://example.com/docs>; rel="help"`},
+				"Cache-Control": []string{"no-store"},
+			},
+		},
+		"List Headers Not Duplicated": {
+			InputProblem: NewRegistered(http.StatusBadRequest, "test").SetHeader("Vary", "Accept"),
+			ExpectedHeaders: http.Header{
+				"Vary": []string{"Accept"},
+			},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			for key, values := range tc.InputHeader {
+				recorder.Header()[key] = values
+			}
+
+			Serve(tc.InputProblem).ServeHTTP(recorder, httptest.NewRequest("", "/", nil))
+
+			if recorder.Code != tc.InputProblem.GetStatus() {
+				t.Errorf("expected %d, got %d", tc.InputProblem.GetStatus(), recorder.Code)
+			}
+
+			for key, values := range tc.ExpectedHeaders {
+				if got := recorder.Header().Values(key); !slices.Equal(got, values) {
+					t.Errorf("expected %s %v, got %v", key, values, got)
+				}
+			}
+		})
+	}
+}
+
+func TestSlog(t *testing.T) {
+	testCases := map[string]struct {
+		InputProblem  Problem
+		ExpectedLevel string
+		ExpectedAttrs []string
+	}{
+		"Server Error": {
+			InputProblem:  NewRegistered(http.StatusInternalServerError, "boom").Wrap(errors.New("connection refused")),
+			ExpectedLevel: "level=ERROR",
+			ExpectedAttrs: []string{
+				"method=GET",
+				"path=/accounts/1",
+				"instance=/accounts/1",
+				"problem.type=about:blank",
+				"problem.status=500",
+				`problem.title="Internal Server Error"`,
+				"problem.detail=boom",
+				`problem.cause="connection refused"`,
+			},
+		},
+		"Client Error": {
+			InputProblem:  &Embed{RegisteredProblem: *NewRegistered(http.StatusNotFound, "")},
+			ExpectedLevel: "level=WARN",
+			ExpectedAttrs: []string{"problem.status=404", `problem.title="Not Found"`},
+		},
+		"Custom Struct Extensions": {
+			InputProblem: &Embed{
+				RegisteredProblem: *NewRegistered(http.StatusForbidden, ""),
+				Extension1:        "e1",
+				Extension2:        "e2",
+			},
+			ExpectedLevel: "level=WARN",
+			ExpectedAttrs: []string{"problem.status=403", "problem.extension1=e1", "problem.extension2=e2"},
+		},
+		"ValidationProblem": {
+			InputProblem:  NewValidation(http.StatusUnprocessableEntity, "invalid").AddPointer("#/age", "must be positive"),
+			ExpectedLevel: "level=WARN",
+			ExpectedAttrs: []string{"problem.status=422", `problem.errors="[map[detail:must be positive pointer:#/age]]"`},
+		},
+		"MapProblem": {
+			InputProblem: func() Problem {
+				m := NewMap(http.StatusBadRequest, "test")
+				m["zeta"] = 1
+				m["alpha"] = "a"
+				return m
+			}(),
+			ExpectedLevel: "level=WARN",
+			ExpectedAttrs: []string{
+				`problem.type=about:blank problem.status=400 problem.title="Bad Request" problem.detail=test problem.instance=/accounts/1 problem.alpha=a problem.zeta=1`,
+			},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger := slog.New(slog.NewTextHandler(&buf, nil))
+
+			recorder := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/accounts/1", nil)
+
+			Serve(tc.InputProblem, WithInstance(PathInstance), WithLogger(logger)).ServeHTTP(recorder, req)
+
+			line := buf.String()
+
+			if !strings.Contains(line, tc.ExpectedLevel) {
+				t.Errorf("expected %s, got %s", tc.ExpectedLevel, line)
+			}
+
+			for _, attr := range tc.ExpectedAttrs {
+				if !strings.Contains(line, attr) {
+					t.Errorf("expected %s, got %s", attr, line)
+				}
+			}
+		})
+	}
+}
+
+func TestSlogLogValue(t *testing.T) {
+	testCases := map[string]struct {
+		InputValue    Problem
+		ExpectedAttrs []string
+	}{
+		"Registered": {
+			InputValue:    NewRegistered(http.StatusNotFound, "").Wrap(errors.New("no rows")),
+			ExpectedAttrs: []string{"p.type=about:blank", "p.status=404", `p.title="Not Found"`, `p.cause="no rows"`},
+		},
+		"Custom Struct": {
+			InputValue: &Embed{
+				RegisteredProblem: *NewRegistered(http.StatusBadRequest, "test"),
+				Extension2:        "e2",
+			},
+			ExpectedAttrs: []string{"p.status=400", "p.extension2=e2"},
+		},
+		"ValidationProblem": {
+			InputValue:    NewValidation(http.StatusUnprocessableEntity, "invalid").AddPointer("#/age", "must be positive"),
+			ExpectedAttrs: []string{"p.status=422", "p.detail=invalid", `p.errors="[map[detail:must be positive pointer:#/age]]"`},
+		},
+		"Typed": {
+			InputValue: func() Problem {
+				p := NewTyped(http.StatusForbidden, "", testOutOfCredit{Balance: 30})
+				p.Wrap(errors.New("no credit"))
+				return p
+			}(),
+			ExpectedAttrs: []string{"p.status=403", "p.balance=30", `p.cause="no credit"`},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			slog.New(slog.NewTextHandler(&buf, nil)).Info("test", "p", LogValue(tc.InputValue))
+
+			for _, attr := range tc.ExpectedAttrs {
+				if !strings.Contains(buf.String(), attr) {
+					t.Errorf("expected %s, got %s", attr, buf.String())
+				}
+			}
+		})
+	}
+}
+
+func TestTraceContext(t *testing.T) {
+	const (
+		traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+		spanID  = "00f067aa0ba902b7"
+	)
+
+	testCases := map[string]struct {
+		InputTraceparent string
+		InputAccept      string
+		ExpectedTrace    bool
+	}{
+		"JSON": {
+			InputTraceparent: "00-" + traceID + "-" + spanID + "-01",
+			InputAccept:      MediaTypeProblemJSON,
+			ExpectedTrace:    true,
+		},
+		"XML": {
+			InputTraceparent: "00-" + traceID + "-" + spanID + "-01",
+			InputAccept:      MediaTypeProblemXML,
+			ExpectedTrace:    true,
+		},
+		"Future Version": {
+			InputTraceparent: "01-" + traceID + "-" + spanID + "-01-extra",
+			InputAccept:      MediaTypeProblemJSON,
+			ExpectedTrace:    true,
+		},
+		"Missing": {
+			InputAccept: MediaTypeProblemJSON,
+		},
+		"Uppercase": {
+			InputTraceparent: "00-" + strings.ToUpper(traceID) + "-" + spanID + "-01",
+			InputAccept:      MediaTypeProblemXML,
+		},
+		"Zero Trace ID": {
+			InputTraceparent: "00-" + strings.Repeat("0", 32) + "-" + spanID + "-01",
+			InputAccept:      MediaTypeProblemJSON,
+		},
+		"Invalid Version": {
+			InputTraceparent: "ff-" + traceID + "-" + spanID + "-01",
+			InputAccept:      MediaTypeProblemJSON,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			req := httptest.NewRequest("", "/", nil)
+			req.Header.Set("Accept", tc.InputAccept)
+			if tc.InputTraceparent != "" {
+				req.Header.Set("traceparent", tc.InputTraceparent)
+			}
+
+			Serve(NewRegistered(http.StatusInternalServerError, ""), WithTraceContext()).ServeHTTP(recorder, req)
+
+			body := recorder.Body.String()
+
+			p, err := ParseResponse(recorder.Result())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			outTraceID, outSpanID, ok := TraceContext(p)
+			if ok != tc.ExpectedTrace {
+				t.Fatalf("expected trace context to be present: %v, got %v (%s)", tc.ExpectedTrace, ok, body)
+			}
+			if !ok {
+				return
+			}
+
+			if outTraceID != traceID || outSpanID != spanID {
+				t.Errorf("expected %s %s, got %s %s", traceID, spanID, outTraceID, outSpanID)
+			}
+		})
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	var metrics Metrics
+
+	outOfCredit := Type{
+		URI:    `https://example.com/probs/"quoted"`,
+		Status: http.StatusForbidden,
+	}
+
+	problems := []Problem{
+		NewRegistered(http.StatusNotFound, ""),
+		NewRegistered(http.StatusNotFound, ""),
+		NewMap(http.StatusInternalServerError, ""),
+		outOfCredit.New(""),
+	}
+
+	for _, p := range problems {
+		Serve(p, WithMetrics(&metrics)).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("", "/", nil))
+	}
+
+	expectedJSON := compactJson(`
+		{
+			"about:blank": {"404": 2, "500": 1},
+			"https://example.com/probs/\"quoted\"": {"403": 1}
+		}
+	`)
+	if s := metrics.String(); s != expectedJSON {
+		t.Errorf("expected %s, got %s", expectedJSON, s)
+	}
+
+	recorder := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(recorder, httptest.NewRequest("", "/metrics", nil))
+
+	expectedText := `# HELP problem_responses_total Problem details responses served, by type URI and status code.
+# TYPE problem_responses_total counter
+problem_responses_total{type="about:blank",status="404"} 2
+problem_responses_total{type="about:blank",status="500"} 1
+problem_responses_total{type="https://example.com/probs/\"quoted\"",status="403"} 1
+`
+	if body := recorder.Body.String(); body != expectedText {
+		t.Errorf("expected %s, got %s", expectedText, body)
+	}
+}
+
+func TestServeMethods(t *testing.T) {
+	p := NewMethodNotAllowed("test", http.MethodGet)
+
+	var mapper Mapper
+	mapper.IsFunc(errors.New("never matched"), nil)
+
+	entryPoints := map[string]http.Handler{
+		"ServeJSON": ServeJSON(p),
+		"ServeXML":  ServeXML(p),
+		"Serve":     Serve(p),
+		"Write": http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			Write(w, r, p)
+		}),
+		"Mapper.Write": http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mapper.Write(w, r, p)
+		}),
+		"Recover": Recover(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			panic("test")
+		})),
+		"Intercept": Intercept(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "test", http.StatusMethodNotAllowed)
+		})),
+	}
+
+	for name, handler := range entryPoints {
+		t.Run(name, func(t *testing.T) {
+			get := httptest.NewRecorder()
+			handler.ServeHTTP(get, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			if get.Body.Len() == 0 {
+				t.Fatalf("expected GET body to be non-empty")
+			}
+
+			head := httptest.NewRecorder()
+			handler.ServeHTTP(head, httptest.NewRequest(http.MethodHead, "/", nil))
+
+			if head.Code != get.Code {
+				t.Errorf("HEAD: expected %d, got %d", get.Code, head.Code)
+			}
+			if head.Body.Len() != 0 {
+				t.Errorf("HEAD: expected empty body, got %s", head.Body.String())
+			}
+			for _, key := range []string{"Content-Type", "Content-Length", "X-Content-Type-Options", "Allow"} {
+				if head.Header().Get(key) != get.Header().Get(key) {
+					t.Errorf("HEAD: expected %s %s, got %s", key, get.Header().Get(key), head.Header().Get(key))
+				}
+			}
+			if head.Header().Get("Content-Length") != strconv.Itoa(get.Body.Len()) {
+				t.Errorf("HEAD: expected Content-Length %d, got %s", get.Body.Len(), head.Header().Get("Content-Length"))
+			}
+
+			options := httptest.NewRecorder()
+			handler.ServeHTTP(options, httptest.NewRequest(http.MethodOptions, "/", nil))
+
+			if options.Code != get.Code {
+				t.Errorf("OPTIONS: expected %d, got %d", get.Code, options.Code)
+			}
+			if options.Body.Len() != 0 {
+				t.Errorf("OPTIONS: expected empty body, got %s", options.Body.String())
+			}
+			if contentType := options.Header().Get("Content-Type"); contentType != "" {
+				t.Errorf("OPTIONS: expected no Content-Type, got %s", contentType)
+			}
+			if contentLength := options.Header().Get("Content-Length"); contentLength != "0" {
+				t.Errorf("OPTIONS: expected Content-Length 0, got %s", contentLength)
+			}
+		})
+	}
+}
+
+// discardResponseWriter is a ResponseWriter that does not allocate when reused
+type discardResponseWriter struct {
+	header http.Header
+}
+
+func (w *discardResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *discardResponseWriter) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+func (w *discardResponseWriter) WriteHeader(statusCode int) {}
+
+func TestStatic(t *testing.T) {
+	p := NewUnauthorized("missing token", `Bearer realm="api"`)
+
+	for _, contentType := range []string{MediaTypeProblemJSON, MediaTypeProblemXML} {
+		t.Run(contentType, func(t *testing.T) {
+			handler := Static(p, contentType)
+
+			for _, method := range []string{http.MethodGet, http.MethodHead, http.MethodOptions} {
+				expected := httptest.NewRecorder()
+				req := httptest.NewRequest(method, "/", nil)
+				req.Header.Set("Accept", contentType)
+				Serve(p).ServeHTTP(expected, req)
+				expected.Header().Del("Vary")
+
+				recorder := httptest.NewRecorder()
+				handler.ServeHTTP(recorder, httptest.NewRequest(method, "/", nil))
+
+				if recorder.Code != expected.Code {
+					t.Errorf("%s: expected %d, got %d", method, expected.Code, recorder.Code)
+				}
+				if recorder.Body.String() != expected.Body.String() {
+					t.Errorf("%s: expected %s, got %s", method, expected.Body.String(), recorder.Body.String())
+				}
+				for key := range expected.Header() {
+					if !slices.Equal(recorder.Header().Values(key), expected.Header().Values(key)) {
+						t.Errorf("%s: expected %s %v, got %v", method, key, expected.Header().Values(key), recorder.Header().Values(key))
+					}
+				}
+			}
+
+			w := &discardResponseWriter{header: http.Header{}}
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			allocs := testing.AllocsPerRun(100, func() {
+				handler.ServeHTTP(w, req)
+			})
+			if allocs != 1 {
+				t.Errorf("expected 1 allocation per request, got %v", allocs)
+			}
+		})
+	}
+}
+
+func TestStaticHeadersNotShared(t *testing.T) {
+	handler := Static(NewUnauthorized("missing token", `Bearer realm="api"`), MediaTypeProblemJSON)
+
+	for _, method := range []string{http.MethodGet, http.MethodOptions} {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(method, "/", nil))
+
+		for _, values := range recorder.Header() {
+			values[0] = "MUTATED"
+			_ = append(values, "APPENDED")
+		}
+	}
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodOptions, "/", nil))
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	for key, values := range recorder.Header() {
+		if slices.Contains(values, "MUTATED") {
+			t.Errorf("expected %s not to be affected by previous responses, got %v", key, values)
+		}
+	}
+	if got := recorder.Header().Get("WWW-Authenticate"); got != `Bearer realm="api"` {
+		t.Errorf("expected WWW-Authenticate %q, got %q", `Bearer realm="api"`, got)
+	}
+}
+
+func TestStaticInvalidContentType(t *testing.T) {
+	defer func() {
+		if v := recover(); v != ErrInvalidContentType {
+			t.Errorf("expected panic with %v, got %v", ErrInvalidContentType, v)
+		}
+	}()
+
+	Static(NewRegistered(http.StatusServiceUnavailable, ""), "text/plain")
+}
+
+type testOutOfCredit struct {
+	Balance  int      `json:"balance" xml:"balance"`
+	Accounts []string `json:"accounts" xml:"accounts>i"`
+
+	// Clashes with the registered member, ignored
+	Type string `json:"type" xml:"type"`
+}
+
+func TestTyped(t *testing.T) {
+	p := NewTyped(http.StatusForbidden, "Your current balance is 30", testOutOfCredit{
+		Balance:  30,
+		Accounts: []string{"/account/12345", "/account/67890"},
+		Type:     "ignored",
+	})
+
+	expectedJSON := compactJson(`
+		{
+			"type": "about:blank",
+			"status": 403,
+			"title": "Forbidden",
+			"detail": "Your current balance is 30",
+			"instance": "",
+			"balance": 30,
+			"accounts": ["/account/12345", "/account/67890"]
+		}
+	`)
+
+	b, err := json.Marshal(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != expectedJSON {
+		t.Errorf("expected %s, got %s", expectedJSON, b)
+	}
+
+	expectedXML := `<problem xmlns="urn:ietf:rfc:7807">` +
+		`<type>about:blank</type><status>403</status><title>Forbidden</title>` +
+		`<detail>Your current balance is 30</detail><instance></instance>` +
+		`<balance>30</balance>` +
+		`<accounts><i>/account/12345</i><i>/account/67890</i></accounts>` +
+		`</problem>`
+
+	b, err = xml.Marshal(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != expectedXML {
+		t.Errorf("expected %s, got %s", expectedXML, b)
+	}
+
+	testCases := map[string]struct {
+		res             *http.Response
+		expectedBalance int
+		expectedProblem Problem
+	}{
+		"JSON": {
+			res:             responseFactory(http.StatusForbidden, MediaTypeProblemJSON, expectedJSON),
+			expectedBalance: 30,
+			expectedProblem: p,
+		},
+		"XML": {
+			res:             responseFactory(http.StatusForbidden, MediaTypeProblemXML, expectedXML),
+			expectedBalance: 30,
+			expectedProblem: p,
+		},
+		"JSON Type Mismatch": {
+			res: responseFactory(http.StatusForbidden, MediaTypeProblemJSON, `{"type":1,"balance":"30"}`),
+			expectedProblem: &RegisteredProblem{
+				Type:   "about:blank",
+				Status: http.StatusForbidden,
+			},
+		},
+		"XML Type Mismatch": {
+			res: responseFactory(http.StatusForbidden, MediaTypeProblemXML, `<problem xmlns="urn:ietf:rfc:7807"><balance>thirty</balance></problem>`),
+			expectedProblem: &RegisteredProblem{
+				Type:   "about:blank",
+				Status: http.StatusForbidden,
+			},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			var out Typed[testOutOfCredit]
+
+			err := ParseResponseCustom(tc.res, &out)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !equalProblems(&out, tc.expectedProblem) {
+				t.Errorf("expected %+v, got %+v", tc.expectedProblem, out.RegisteredProblem)
+			}
+			if out.Extensions.Balance != tc.expectedBalance {
+				t.Errorf("expected balance %d, got %d", tc.expectedBalance, out.Extensions.Balance)
+			}
+			if tc.expectedBalance != 0 && !slices.Equal(out.Extensions.Accounts, p.Extensions.Accounts) {
+				t.Errorf("expected accounts %v, got %v", p.Extensions.Accounts, out.Extensions.Accounts)
+			}
+		})
+	}
+
+	var out Typed[testOutOfCredit]
+	if err := xml.Unmarshal([]byte(expectedXML), &out); err != nil {
+		t.Fatal(err)
+	}
+	if !equalProblems(&out, p) || !slices.Equal(out.Extensions.Accounts, p.Extensions.Accounts) {
+		t.Errorf("expected %+v, got %+v", p, out)
+	}
+
+	recorder := httptest.NewRecorder()
+	ServeXML(p, WithRedaction(Redaction{StatusClasses: []int{4}, AllowedExtensions: []string{"balance"}})).
+		ServeHTTP(recorder, httptest.NewRequest("", "/", nil))
+
+	if body := recorder.Body.String(); !strings.Contains(body, "<balance>30</balance>") || strings.Contains(body, "accounts") {
+		t.Errorf("expected only the allowed extension members, got %s", body)
+	}
+}
+
+func TestTypedExtensionKinds(t *testing.T) {
+	testCases := map[string]struct {
+		InputProblem Problem
+		InputAccept  string
+		ExpectedBody string
+	}{
+		"Anonymous JSON": {
+			InputProblem: NewTyped(http.StatusForbidden, "d", struct {
+				B int `json:"b" xml:"b"`
+			}{3}),
+			InputAccept:  MediaTypeProblemJSON,
+			ExpectedBody: `{"type":"about:blank","status":403,"title":"Forbidden","detail":"d","instance":"","b":3}`,
+		},
+		"Anonymous XML": {
+			InputProblem: NewTyped(http.StatusForbidden, "d", struct {
+				B int `json:"b" xml:"b"`
+			}{3}),
+			InputAccept: MediaTypeProblemXML,
+			ExpectedBody: xml.Header + `<problem xmlns="urn:ietf:rfc:7807"><type>about:blank</type><status>403</status>` +
+				`<title>Forbidden</title><detail>d</detail><instance></instance><b>3</b></problem>`,
+		},
+		"Nil Pointer JSON": {
+			InputProblem: NewTyped[*testOutOfCredit](http.StatusForbidden, "d", nil),
+			InputAccept:  MediaTypeProblemJSON,
+			ExpectedBody: `{"type":"about:blank","status":403,"title":"Forbidden","detail":"d","instance":""}`,
+		},
+		"Nil Pointer XML": {
+			InputProblem: NewTyped[*testOutOfCredit](http.StatusForbidden, "d", nil),
+			InputAccept:  MediaTypeProblemXML,
+			ExpectedBody: xml.Header + `<problem xmlns="urn:ietf:rfc:7807"><type>about:blank</type><status>403</status>` +
+				`<title>Forbidden</title><detail>d</detail><instance></instance></problem>`,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			req := httptest.NewRequest("", "/", nil)
+			req.Header.Set("Accept", tc.InputAccept)
+
+			Serve(tc.InputProblem).ServeHTTP(recorder, req)
+
+			if recorder.Code != http.StatusForbidden {
+				t.Errorf("expected %d, got %d", http.StatusForbidden, recorder.Code)
+			}
+			if body := recorder.Body.String(); strings.TrimSpace(body) != strings.TrimSpace(tc.ExpectedBody) {
+				t.Errorf("expected %s, got %s", tc.ExpectedBody, body)
+			}
+		})
+	}
+}
+
+func TestEncoder(t *testing.T) {
+	m := NewMap(http.StatusForbidden, "")
+	m["balance"] = 30
+	m["accounts"] = []string{"/account/12345"}
+
+	testCases := map[string]struct {
+		InputProblem     Problem
+		InputEncoder     Encoder
+		InputContentType string
+		ExpectedBody     string
+	}{
+		"Zero JSON": {
+			InputProblem:     NewRegistered(http.StatusForbidden, ""),
+			InputContentType: MediaTypeProblemJSON,
+			ExpectedBody:     `{"type":"about:blank","status":403,"title":"Forbidden","detail":"","instance":""}`,
+		},
+		"Zero XML": {
+			InputProblem:     NewRegistered(http.StatusForbidden, ""),
+			InputContentType: MediaTypeProblemXML,
+			ExpectedBody: xml.Header + `<problem xmlns="urn:ietf:rfc:7807">` +
+				`<type>about:blank</type><status>403</status><title>Forbidden</title>` +
+				`<detail></detail><instance></instance></problem>`,
+		},
+		"Omit Empty JSON": {
+			InputProblem:     NewRegistered(http.StatusForbidden, ""),
+			InputEncoder:     Encoder{OmitEmpty: true},
+			InputContentType: MediaTypeProblemJSON,
+			ExpectedBody:     `{"type":"about:blank","status":403,"title":"Forbidden"}`,
+		},
+		"Omit Empty XML": {
+			InputProblem:     NewRegistered(http.StatusForbidden, ""),
+			InputEncoder:     Encoder{OmitEmpty: true},
+			InputContentType: MediaTypeProblemXML,
+			ExpectedBody: xml.Header + `<problem xmlns="urn:ietf:rfc:7807">` +
+				`<type>about:blank</type><status>403</status><title>Forbidden</title></problem>`,
+		},
+		"Omit Empty Keeps Extensions": {
+			InputProblem:     NewTyped(http.StatusForbidden, "", testOutOfCredit{}),
+			InputEncoder:     Encoder{OmitEmpty: true},
+			InputContentType: MediaTypeProblemJSON,
+			ExpectedBody:     `{"type":"about:blank","status":403,"title":"Forbidden","balance":0,"accounts":null}`,
+		},
+		"Canonical JSON": {
+			InputProblem:     m,
+			InputEncoder:     Encoder{Canonical: true},
+			InputContentType: MediaTypeProblemJSON,
+			ExpectedBody:     `{"type":"about:blank","status":403,"title":"Forbidden","detail":"","accounts":["/account/12345"],"balance":30}`,
+		},
+		"Canonical XML": {
+			InputProblem:     NewTyped(http.StatusForbidden, "", testOutOfCredit{Balance: 30}),
+			InputEncoder:     Encoder{Canonical: true},
+			InputContentType: MediaTypeProblemXML,
+			ExpectedBody: xml.Header + `<problem xmlns="urn:ietf:rfc:7807">` +
+				`<type>about:blank</type><status>403</status><title>Forbidden</title>` +
+				`<detail></detail><instance></instance><accounts></accounts><balance>30</balance></problem>`,
+		},
+		"Indented JSON": {
+			InputProblem:     m,
+			InputEncoder:     Encoder{OmitEmpty: true, Canonical: true, Indent: "  "},
+			InputContentType: "application/json",
+			ExpectedBody: `{
+  "type": "about:blank",
+  "status": 403,
+  "title": "Forbidden",
+  "accounts": [
+    "/account/12345"
+  ],
+  "balance": 30
+}`,
+		},
+		"Indented XML": {
+			InputProblem:     m,
+			InputEncoder:     Encoder{OmitEmpty: true, Canonical: true, Indent: "  "},
+			InputContentType: "application/xml",
+			ExpectedBody: xml.Header + `<problem xmlns="urn:ietf:rfc:7807">
+  <type>about:blank</type>
+  <status>403</status>
+  <title>Forbidden</title>
+  <accounts>
+    <i>/account/12345</i>
+  </accounts>
+  <balance>30</balance>
+</problem>`,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			b, err := tc.InputEncoder.Marshal(tc.InputProblem, tc.InputContentType)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(b) != tc.ExpectedBody {
+				t.Errorf("expected %s, got %s", tc.ExpectedBody, b)
+			}
+
+			// The serving helpers send the same bytes, followed by a newline in JSON
+			handlers := map[string]http.Handler{
+				"Serve": Serve(tc.InputProblem, WithEncoder(tc.InputEncoder)),
+			}
+			switch tc.InputContentType {
+			case MediaTypeProblemJSON:
+				handlers["ServeJSON"] = ServeJSON(tc.InputProblem, WithEncoder(tc.InputEncoder))
+				handlers["Static"] = Static(tc.InputProblem, tc.InputContentType, WithEncoder(tc.InputEncoder))
+			case MediaTypeProblemXML:
+				handlers["ServeXML"] = ServeXML(tc.InputProblem, WithEncoder(tc.InputEncoder))
+				handlers["Static"] = Static(tc.InputProblem, tc.InputContentType, WithEncoder(tc.InputEncoder))
+			}
+
+			for handlerName, handler := range handlers {
+				recorder := httptest.NewRecorder()
+				req := httptest.NewRequest("", "/", nil)
+				req.Header.Set("Accept", tc.InputContentType)
+				handler.ServeHTTP(recorder, req)
+
+				if body := strings.TrimSuffix(recorder.Body.String(), "\n"); body != tc.ExpectedBody {
+					t.Errorf("%s: expected %s, got %s", handlerName, tc.ExpectedBody, body)
+				}
+			}
+		})
+	}
+
+	if _, err := Marshal(m, "text/plain"); !errors.Is(err, ErrInvalidContentType) {
+		t.Errorf("expected %v, got %v", ErrInvalidContentType, err)
+	}
+
+	b, err := Marshal(m, MediaTypeProblemXML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected, _ := xml.Marshal(m); string(b) != xml.Header+string(expected) {
+		t.Errorf("expected %s, got %s", expected, b)
+	}
+}
+
+func TestParseResponseStrict(t *testing.T) {
+	type violation struct {
+		Member string
+		Err    error
+	}
+
+	testCases := map[string]struct {
+		InputResponse      *http.Response
+		InputProblem       Problem
+		ExpectedViolations []violation
+		ExpectedDecodeErr  bool
+	}{
+		"JSON: Conforming": {
+			InputResponse: responseFactory(http.StatusUnprocessableEntity, MediaTypeProblemJSON, `{
+				"type": "https://example.com/probs/validation",
+				"status": 422,
+				"title": "Unprocessable Entity",
+				"instance": "/orders/12%20345",
+				"errors": [{"detail": "must be positive", "pointer": "#/age"}]
+			}`),
+			InputProblem: &ValidationProblem{},
+		},
+		"XML: Conforming": {
+			InputResponse: responseFactory(http.StatusForbidden, MediaTypeProblemXML, xml.Header+`
+				<problem xmlns="urn:ietf:rfc:7807">
+					<type>about:blank</type>
+					<status>403</status>
+					<balance>30</balance>
+				</problem>
+			`),
+			InputProblem: &Typed[testOutOfCredit]{},
+		},
+		"JSON: Violations": {
+			InputResponse: responseFactory(http.StatusBadRequest, MediaTypeProblemJSON, `{
+				"status": 500,
+				"title": 123,
+				"instance": "not a uri",
+				"title": "Bad Request",
+				"errors": [{"detail": 1, "pointer": "#/a", "pointer": "#/b"}]
+			}`),
+			InputProblem: &ValidationProblem{},
+			ExpectedViolations: []violation{
+				{"status", ErrStatusMismatch},
+				{"title", ErrMemberType},
+				{"instance", ErrInvalidURI},
+				{"title", ErrDuplicateMember},
+				{"errors", ErrDuplicateMember},
+				{"errors", ErrMemberType},
+				{"type", ErrMissingType},
+			},
+		},
+		"JSON: Status Type": {
+			InputResponse:      responseFactory(http.StatusBadRequest, MediaTypeProblemJSON, `{"type":"about:blank","status":"400"}`),
+			InputProblem:       &MapProblem{},
+			ExpectedViolations: []violation{{"status", ErrMemberType}},
+		},
+		"JSON: Typed Extension Type": {
+			InputResponse:      responseFactory(http.StatusForbidden, MediaTypeProblemJSON, `{"type":"about:blank","balance":"30"}`),
+			InputProblem:       &Typed[testOutOfCredit]{},
+			ExpectedViolations: []violation{{"balance", ErrMemberType}},
+		},
+		"XML: Violations": {
+			InputResponse: responseFactory(http.StatusForbidden, MediaTypeProblemXML, `<problem xmlns="urn:ietf:rfc:7807">`+
+				`<type>a b</type><status>x</status><balance>thirty</balance><balance>30</balance>`+
+				`</problem>`),
+			InputProblem: &Typed[testOutOfCredit]{},
+			ExpectedViolations: []violation{
+				{"type", ErrInvalidURI},
+				{"status", ErrMemberType},
+				{"balance", ErrMemberType},
+				{"balance", ErrDuplicateMember},
+			},
+		},
+		"XML: Missing Namespace": {
+			InputResponse:      responseFactory(http.StatusForbidden, MediaTypeProblemXML, `<problem><type>about:blank</type></problem>`),
+			InputProblem:       &RegisteredProblem{},
+			ExpectedViolations: []violation{{"", ErrXMLNamespace}},
+			ExpectedDecodeErr:  true,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			err := ParseResponseStrict(tc.InputResponse, tc.InputProblem)
+
+			var ce *ConformanceError
+			if len(tc.ExpectedViolations) == 0 {
+				if err != nil {
+					t.Fatalf("expected error to be nil, got %v", err)
+				}
+				return
+			}
+			if !errors.As(err, &ce) {
+				t.Fatalf("expected a *ConformanceError, got %v", err)
+			}
+
+			if len(ce.Violations) != len(tc.ExpectedViolations) {
+				t.Fatalf("expected %d violations, got %v", len(tc.ExpectedViolations), ce)
+			}
+			for i, expected := range tc.ExpectedViolations {
+				v := ce.Violations[i]
+				if v.Member != expected.Member || !errors.Is(v, expected.Err) {
+					t.Errorf("expected violation %d to be %q %v, got %v", i, expected.Member, expected.Err, v)
+				}
+				if !errors.Is(err, expected.Err) {
+					t.Errorf("expected error to match %v", expected.Err)
+				}
+			}
+
+			if tc.ExpectedDecodeErr {
+				if errors.Unwrap(err) != nil {
+					t.Errorf("expected a joined error, got %v", err)
+				}
+				return
+			}
+			if err != ce {
+				t.Errorf("expected only the *ConformanceError, got %v", err)
+			}
+			if tc.InputProblem.GetStatus() != tc.InputResponse.StatusCode {
+				t.Errorf("expected problem to be populated, got %+v", tc.InputProblem)
+			}
+		})
+	}
+
+	// The lenient parsing ignores the same deviations
+	res := responseFactory(http.StatusForbidden, MediaTypeProblemJSON, `{"title":1,"title":"Forbidden","balance":"30"}`)
+	var p Typed[testOutOfCredit]
+	if err := ParseResponseCustom(res, &p); err != nil {
+		t.Fatal(err)
+	}
+	if p.Title != "Forbidden" || p.Type != "about:blank" {
+		t.Errorf("expected lenient parsing, got %+v", p)
+	}
+}
+
+func BenchmarkServeJSON(b *testing.B) {
+	handler := ServeJSON(NewRegistered(http.StatusServiceUnavailable, "down for maintenance"))
+
+	w := &discardResponseWriter{header: http.Header{}}
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	b.ReportAllocs()
+	for range b.N {
+		handler.ServeHTTP(w, req)
+	}
+}
+
+func BenchmarkStatic(b *testing.B) {
+	handler := Static(NewRegistered(http.StatusServiceUnavailable, "down for maintenance"), MediaTypeProblemJSON)
+
+	w := &discardResponseWriter{header: http.Header{}}
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	b.ReportAllocs()
+	for range b.N {
+		handler.ServeHTTP(w, req)
+	}
+}
@@ -0,0 +1,95 @@
+package problem
+
+import (
+	"errors"
+	"maps"
+	"net/http"
+	"runtime/debug"
+)
+
+// Recover returns a middleware that recovers from panics in next, answering the request with a
+// 500 Internal Server Error problem, served in the format negotiated by [Serve].
+//
+// The panic value is never exposed to the client, use [WithPanicHook] to log it. If next
+// already sent the response headers, nothing is written and the connection is left as is.
+// Otherwise, headers set by next are dropped, but the ones set by outer handlers before calling
+// the middleware are kept.
+//
+// Panics with [http.ErrAbortHandler] are re-panicked, so the server can abort the response as
+// intended.
+//
+// Accepted options are [WithPanicType] and [WithPanicHook], other options are passed to [Write].
+func Recover(next http.Handler, opts ...Option) http.Handler {
+	c := newConfig(opts)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tw := &trackingWriter{ResponseWriter: w}
+
+		// Headers set by outer handlers, like CORS or request correlation ones, must survive
+		header := w.Header().Clone()
+
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+
+			if err, ok := v.(error); ok && errors.Is(err, http.ErrAbortHandler) {
+				panic(v)
+			}
+
+			if c.panicHook != nil {
+				c.panicHook(v, debug.Stack())
+			}
+
+			if tw.wroteHeader {
+				return
+			}
+
+			// Headers set by next before panicking do not describe the problem response, only
+			// the ones set before calling next are kept
+			h := w.Header()
+			clear(h)
+			maps.Copy(h, header)
+
+			p := NewRegistered(http.StatusInternalServerError, "")
+			if c.panicType != "" {
+				p.Type = c.panicType
+			}
+
+			Write(w, r, p, opts...)
+		}()
+
+		next.ServeHTTP(tw, r)
+	})
+}
+
+// trackingWriter records whether the response headers were already sent.
+type trackingWriter struct {
+	http.ResponseWriter
+
+	wroteHeader bool
+}
+
+func (w *trackingWriter) WriteHeader(statusCode int) {
+	// 1xx responses are informational, headers of the final response are not sent yet
+	if statusCode >= 200 {
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *trackingWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *trackingWriter) Flush() {
+	w.wroteHeader = true
+	_ = http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Unwrap allows [http.ResponseController] to access the underlying ResponseWriter.
+func (w *trackingWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
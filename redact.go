@@ -0,0 +1,40 @@
+package problem
+
+import "slices"
+
+// Redaction configures the redaction of problems sent to clients, see [WithRedaction].
+type Redaction struct {
+	// Classes of the status codes of the problems to be redacted, e.g. 5 for 5xx and 4 for 4xx.
+	// If empty, only 5xx problems are redacted.
+	StatusClasses []int
+
+	// Replaces the "detail" member of redacted problems, if empty the member is emptied.
+	Detail string
+
+	// Names of the extension members safe to be sent to clients, other extension members are
+	// removed from redacted problems.
+	AllowedExtensions []string
+}
+
+func (rd *Redaction) applies(status int) bool {
+	if len(rd.StatusClasses) == 0 {
+		return status/100 == 5
+	}
+	return slices.Contains(rd.StatusClasses, status/100)
+}
+
+// apply redacts the problem wrapped by v, which is cloned before being modified.
+func (rd *Redaction) apply(v *problemView) {
+	if !rd.applies(v.GetStatus()) {
+		return
+	}
+
+	if v.GetDetail() != rd.Detail {
+		v.Problem = cloneProblem(v.Problem)
+		v.setDetail(rd.Detail)
+	}
+
+	v.omit = func(name string) bool {
+		return !slices.Contains(rd.AllowedExtensions, name)
+	}
+}
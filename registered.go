@@ -1,5 +1,17 @@
 package problem
 
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
 // Media type for JSON Problem Details
 //
 // https://datatracker.ietf.org/doc/html/rfc9457#name-iana-considerations
@@ -10,6 +22,11 @@ const MediaTypeProblemJSON = "application/problem+json"
 // https://datatracker.ietf.org/doc/html/rfc9457#name-iana-considerations
 const MediaTypeProblemXML = "application/problem+xml"
 
+// Namespace of the XML Problem Details root element
+//
+// https://www.rfc-editor.org/rfc/rfc9457.html#name-xml-format
+const xmlNamespace = "urn:ietf:rfc:7807"
+
 // Problem details registered members, as those specified in
 // https://datatracker.ietf.org/doc/html/rfc9457#name-members-of-a-problem-detail
 //
@@ -29,6 +46,22 @@ type RegisteredProblem struct {
 	Title    string `json:"title" xml:"title"`
 	Detail   string `json:"detail" xml:"detail"`
 	Instance string `json:"instance" xml:"instance"`
+
+	// Underlying error, see [RegisteredProblem.Wrap]. Never marshaled.
+	cause error
+
+	// Stack captured by the constructor, see [SetCaptureStacks]. Never marshaled.
+	stack *stackTrace
+
+	// See [RegisteredProblem.SetRetryAfter]. Never marshaled, served as the Retry-After header.
+	retry retry
+
+	// See [RegisteredProblem.SetHeader]. Never marshaled. A pointer so the struct stays
+	// comparable.
+	header *http.Header
+
+	// See [TraceContext]. Only set by the parsing functions, never marshaled.
+	trace traceContext
 }
 
 // RegisteredProblem implements Problem
@@ -54,6 +87,73 @@ func (r RegisteredProblem) GetInstance() string {
 	return r.Instance
 }
 
+// Error returns the status code, title and detail of the problem, followed by the message of
+// the wrapped cause, if any.
+func (r RegisteredProblem) Error() string {
+	msg := problemMessage(r.Status, r.Title, r.Detail)
+	if r.cause != nil {
+		msg += ": " + r.cause.Error()
+	}
+	return msg
+}
+
+// Wrap sets err as the cause of r, and returns r. The cause is available through
+// [RegisteredProblem.Unwrap], so errors.Is and errors.As can find it, but it is never
+// marshaled, so it is safe to wrap errors that must not reach clients:
+//
+//	if err != nil {
+//	    return problem.NewRegistered(http.StatusServiceUnavailable, "try again later").Wrap(err)
+//	}
+//
+// When called on a custom struct that embeds RegisteredProblem, the cause is set on the struct
+// but the returned value is the embedded *RegisteredProblem, so return the struct instead.
+func (r *RegisteredProblem) Wrap(err error) *RegisteredProblem {
+	r.cause = err
+	return r
+}
+
+// Unwrap returns the cause set by [RegisteredProblem.Wrap], or nil.
+func (r RegisteredProblem) Unwrap() error {
+	return r.cause
+}
+
+// SetRetryAfter sets the delay after which the client may retry the request, usually for 429 Too
+// Many Requests and 503 Service Unavailable problems, and returns r.
+//
+// The serving helpers send it as the Retry-After header, in seconds, and optionally as the
+// "retry_after" extension member, see [WithRetryAfterMember].
+func (r *RegisteredProblem) SetRetryAfter(d time.Duration) *RegisteredProblem {
+	r.retry = retry{delay: d}
+	return r
+}
+
+// SetRetryAt is like [RegisteredProblem.SetRetryAfter] but sets an absolute time, sent in the
+// Retry-After header as an HTTP-date.
+func (r *RegisteredProblem) SetRetryAt(t time.Time) *RegisteredProblem {
+	r.retry = retry{at: t}
+	return r
+}
+
+func (r RegisteredProblem) getRetry() retry {
+	return r.retry
+}
+
+func (r *RegisteredProblem) setRetry(rt retry) {
+	r.retry = rt
+}
+
+func (r RegisteredProblem) getTrace() traceContext {
+	return r.trace
+}
+
+func (r *RegisteredProblem) setTrace(t traceContext) {
+	r.trace = t
+}
+
+func (r RegisteredProblem) capturedStack() *stackTrace {
+	return r.stack
+}
+
 func (r *RegisteredProblem) setStatus(status int) {
 	r.Status = status
 }
@@ -62,14 +162,31 @@ func (r *RegisteredProblem) setTypeAboutBlank() {
 	r.Type = "about:blank"
 }
 
-// Problem details map, this implementation is ONLY suitable for JSON marshaling/unmarshaling,
-// it does not support XML.
+func (r *RegisteredProblem) setInstance(instance string) {
+	r.Instance = instance
+}
+
+func (r *RegisteredProblem) setTitle(title string) {
+	r.Title = title
+}
+
+func (r *RegisteredProblem) setDetail(detail string) {
+	r.Detail = detail
+}
+
+// Problem details map, this implementation is suitable for both JSON and XML
+// marshaling/unmarshaling.
 //
 // This implementation is useful when you want to unmarshal an "extension member", which you
 // would otherwise have to create a custom struct for. You can get extension members with map
 // access notation:
 //
 //	mapProblem["extension_member"]
+//
+// In XML, members are encoded following
+// https://www.rfc-editor.org/rfc/rfc9457.html#name-xml-format, see [MapProblem.MarshalXML].
+// Since XML has no value types, members decoded from XML are strings, []any (arrays) or
+// map[string]any (objects), except "status" which is decoded as an int.
 type MapProblem map[string]any
 
 // MapProblem implements Problem
@@ -100,6 +217,41 @@ func (m MapProblem) GetInstance() string {
 	return v
 }
 
+// Error returns the status code, title and detail of the problem.
+func (m MapProblem) Error() string {
+	return problemMessage(m.GetStatus(), m.GetTitle(), m.GetDetail())
+}
+
+func (m MapProblem) getRetry() retry {
+	var s float64
+	switch v := m["retry_after"].(type) {
+	case int:
+		s = float64(v)
+	case int64:
+		s = float64(v)
+	case float64:
+		s = v
+	case json.Number:
+		s, _ = v.Float64()
+	case string:
+		// Decoded from XML
+		s, _ = strconv.ParseFloat(v, 64)
+	}
+	return retry{delay: time.Duration(s * float64(time.Second))}
+}
+
+// The Retry-After header of parsed problems is not added to the members, see [ParseRetryAfter]
+func (m MapProblem) setRetry(r retry) {}
+
+func (m MapProblem) getTrace() traceContext {
+	traceID, _ := m["trace_id"].(string)
+	spanID, _ := m["span_id"].(string)
+	return traceContext{traceID: traceID, spanID: spanID}
+}
+
+// The trace context is already present in the members
+func (m MapProblem) setTrace(t traceContext) {}
+
 func (m MapProblem) setStatus(status int) {
 	m["status"] = status
 }
@@ -107,3 +259,125 @@ func (m MapProblem) setStatus(status int) {
 func (m MapProblem) setTypeAboutBlank() {
 	m["type"] = "about:blank"
 }
+
+func (m MapProblem) setInstance(instance string) {
+	m["instance"] = instance
+}
+
+func (m MapProblem) setTitle(title string) {
+	m["title"] = title
+}
+
+func (m MapProblem) setDetail(detail string) {
+	m["detail"] = detail
+}
+
+// MarshalXML encodes m as a <problem> element in the 'urn:ietf:rfc:7807' namespace, following
+// the rules of https://www.rfc-editor.org/rfc/rfc9457.html#name-xml-format
+//
+// Registered members are encoded first, in the order they are defined by RFC 9457, followed by
+// the extension members sorted by name. Every member is encoded as its JSON value would be:
+// arrays as elements with an <i> child element for every item, objects as elements with a child
+// element for every member, and strings, numbers and booleans as text.
+//
+// An error is returned if the name of a member, or of a member of an object value, is not a
+// valid XML element name, like "bad key".
+func (m MapProblem) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	start = xml.StartElement{Name: xml.Name{Space: xmlNamespace, Local: "problem"}}
+
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(m))
+	for _, name := range registeredMembers {
+		if _, ok := m[name]; ok {
+			names = append(names, name)
+		}
+	}
+
+	extensions := make([]string, 0, len(m))
+	for name := range m {
+		if !isRegisteredMember(name) {
+			extensions = append(extensions, name)
+		}
+	}
+	slices.Sort(extensions)
+
+	for _, name := range append(names, extensions...) {
+		b, err := json.Marshal(m[name])
+		if err != nil {
+			return err
+		}
+		if err := encodeAppendixB(e, name, b); err != nil {
+			return err
+		}
+	}
+
+	return e.EncodeToken(start.End())
+}
+
+// UnmarshalXML decodes the <problem> element into m, reversing the rules of
+// [MapProblem.MarshalXML]. A "status" member that is not an integer is ignored.
+func (m *MapProblem) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	if start.Name.Space != xmlNamespace || start.Name.Local != "problem" {
+		return fmt.Errorf("problem: expected element <problem> in namespace %s, got <%s> in namespace %s",
+			xmlNamespace, start.Name.Local, start.Name.Space)
+	}
+
+	v, err := decodeAppendixB(d)
+	if err != nil {
+		return err
+	}
+
+	if *m == nil {
+		*m = MapProblem{}
+	}
+
+	members, _ := v.(map[string]any)
+	for name, value := range members {
+		(*m)[name] = value
+	}
+
+	if s, ok := members["status"].(string); ok {
+		if status, err := strconv.Atoi(strings.TrimSpace(s)); err == nil {
+			(*m)["status"] = status
+		} else {
+			delete(*m, "status")
+		}
+	}
+
+	return nil
+}
+
+func (m *MapProblem) unmarshalProblemXML(b []byte) error {
+	d := xml.NewDecoder(bytes.NewReader(b))
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return err
+		}
+		if start, ok := tok.(xml.StartElement); ok {
+			return m.UnmarshalXML(d, start)
+		}
+	}
+}
+
+func isMapProblem(p Problem) bool {
+	switch p.(type) {
+	case MapProblem, *MapProblem:
+		return true
+	}
+	return false
+}
+
+func problemMessage(status int, title, detail string) string {
+	msg := strconv.Itoa(status)
+	if title != "" {
+		msg += " " + title
+	}
+	if detail != "" {
+		msg += ": " + detail
+	}
+	return msg
+}
@@ -0,0 +1,98 @@
+package problem
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// retry is the delay after which the client may retry a request, either relative or absolute.
+type retry struct {
+	delay time.Duration
+	at    time.Time
+}
+
+func (r retry) isZero() bool {
+	return r.delay <= 0 && r.at.IsZero()
+}
+
+// duration returns the delay from now.
+func (r retry) duration() time.Duration {
+	if !r.at.IsZero() {
+		return max(time.Until(r.at), 0)
+	}
+	return r.delay
+}
+
+// header returns the value of the Retry-After header, as an HTTP-date for absolute retries and
+// as delay-seconds for relative ones.
+func (r retry) header() string {
+	if !r.at.IsZero() {
+		return r.at.UTC().Format(http.TimeFormat)
+	}
+	return strconv.FormatInt(seconds(r.delay), 10)
+}
+
+// seconds returns d in whole seconds, rounded up.
+func seconds(d time.Duration) int64 {
+	return int64(math.Ceil(d.Seconds()))
+}
+
+// parseRetryAfter parses the value of a Retry-After header, in either of the formats defined in
+// https://www.rfc-editor.org/rfc/rfc9110.html#name-retry-after
+func parseRetryAfter(value string) (retry, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return retry{}, false
+	}
+
+	if s, err := strconv.ParseUint(value, 10, 32); err == nil {
+		return retry{delay: time.Duration(s) * time.Second}, true
+	}
+
+	if at, err := http.ParseTime(value); err == nil {
+		return retry{at: at}, true
+	}
+
+	return retry{}, false
+}
+
+// RetryAfter returns the delay after which the client may retry the request that caused p.
+//
+// On the server side, the delay is the one set with [RegisteredProblem.SetRetryAfter] or
+// [RegisteredProblem.SetRetryAt], or the "retry_after" member (in seconds) of a [MapProblem].
+//
+// On the client side, problems filled by [ParseResponseCustom] carry the value of the
+// Retry-After header of the response. A [MapProblem], such as the ones returned by
+// [ParseResponse], only carries its "retry_after" member, if any, since the header is never
+// added to its members; use [ParseRetryAfter] to get the header instead.
+func RetryAfter(p Problem) (time.Duration, bool) {
+	r := p.getRetry()
+	if r.isZero() {
+		return 0, false
+	}
+	return r.duration(), true
+}
+
+// ParseRetryAfter returns the delay in the Retry-After header of res, in either of the formats
+// defined by RFC 9110. An HTTP-date is counted from now.
+//
+// It reports false if the header is missing or malformed.
+func ParseRetryAfter(res *http.Response) (time.Duration, bool) {
+	r, ok := parseRetryAfter(res.Header.Get("Retry-After"))
+	if !ok {
+		return 0, false
+	}
+	return r.duration(), true
+}
+
+// retryMember returns the "retry_after" extension member for r, in seconds.
+func retryMember(r retry) member {
+	return member{
+		name:  "retry_after",
+		value: json.RawMessage(strconv.FormatInt(seconds(r.duration()), 10)),
+	}
+}
@@ -0,0 +1,92 @@
+package problem
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"slices"
+)
+
+// LogValue returns the members of the problem as a group, so the problem is logged as grouped
+// attributes by [log/slog]. Registered members are logged first, in the order they are defined
+// by RFC 9457, followed by the extension members sorted by name.
+func (m MapProblem) LogValue() slog.Value {
+	attrs := make([]slog.Attr, 0, len(m))
+
+	for _, name := range registeredMembers {
+		if v, ok := m[name]; ok {
+			attrs = append(attrs, slog.Any(name, v))
+		}
+	}
+
+	extensions := make([]string, 0, len(m))
+	for name := range m {
+		if !isRegisteredMember(name) {
+			extensions = append(extensions, name)
+		}
+	}
+	slices.Sort(extensions)
+
+	for _, name := range extensions {
+		attrs = append(attrs, slog.Any(name, m[name]))
+	}
+
+	return slog.GroupValue(attrs...)
+}
+
+// MapProblem implements slog.LogValuer
+var _ slog.LogValuer = MapProblem(nil)
+
+// LogValue returns the members of p as a group, so the problem is logged as grouped attributes
+// by [log/slog]:
+//
+//	logger.Warn("request failed", "problem", problem.LogValue(p))
+//
+// The members are taken from the JSON encoding of p, so every extension member is included.
+// Registered members are logged first, in the order they are defined by RFC 9457, omitting the
+// empty ones, followed by the extension members sorted by name, and the wrapped cause, if any,
+// as "cause".
+//
+// [MapProblem] implements [slog.LogValuer] itself. The struct problems do not, since the method
+// would be promoted to custom structs embedding them, which would then be logged without their
+// extension members.
+func LogValue(p Problem) slog.Value {
+	members, err := problemMembers(p)
+	if err != nil {
+		return slog.GroupValue(
+			slog.String("type", p.GetType()),
+			slog.Int("status", p.GetStatus()),
+			slog.String("title", p.GetTitle()),
+		)
+	}
+
+	members = orderMembers(Encoder{OmitEmpty: true, Canonical: true}, members)
+
+	attrs := make([]slog.Attr, 0, len(members)+1)
+	for _, m := range members {
+		dec := json.NewDecoder(bytes.NewReader(m.value))
+		dec.UseNumber()
+
+		var v any
+		if dec.Decode(&v) == nil {
+			attrs = append(attrs, slog.Any(m.name, v))
+		}
+	}
+
+	if u, ok := p.(interface{ Unwrap() error }); ok && u.Unwrap() != nil {
+		attrs = append(attrs, slog.String("cause", u.Unwrap().Error()))
+	}
+
+	return slog.GroupValue(attrs...)
+}
+
+// logLevel returns the level problems with the given status code are logged at.
+func logLevel(status int) slog.Level {
+	switch {
+	case status >= 500:
+		return slog.LevelError
+	case status >= 400:
+		return slog.LevelWarn
+	}
+	return slog.LevelInfo
+}
@@ -0,0 +1,107 @@
+package problem
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+)
+
+// staticHandler serves a problem encoded at construction time.
+type staticHandler struct {
+	status int
+	body   []byte
+
+	// Response headers, copied into the response header map by every request
+	header http.Header
+
+	// Number of header values in header, including the OPTIONS Content-Length
+	values int
+}
+
+// Static returns a Handler that serves p in the format of contentType, which must be
+// 'application/problem+json' or 'application/problem+xml', like [ServeJSON] and [ServeXML] do,
+// including the headers carried by p and the handling of HEAD and OPTIONS requests.
+//
+// p is encoded once, when Static is called, and every request is answered with the same bytes.
+// The only allocation per request is the backing array of the header values, so every response
+// gets its own values and handlers modifying them after serving do not affect other responses.
+// It is suited for fixed problems, like a canned 401 Unauthorized or a 503 Service Unavailable
+// maintenance response. Modifying p after calling Static has no effect.
+//
+// Of the options, only [WithEncoder] applies, since the rest depend on the request.
+//
+// Static panics if contentType is not valid, or p cannot be encoded.
+func Static(p Problem, contentType string, opts ...Option) http.Handler {
+	if contentType != MediaTypeProblemJSON && contentType != MediaTypeProblemXML {
+		panic(ErrInvalidContentType)
+	}
+
+	buf := getBuffer()
+	defer bufferPool.Put(buf)
+
+	if err := encodeProblem(buf, p, contentType, newConfig(opts)); err != nil {
+		panic(err)
+	}
+
+	s := &staticHandler{
+		status: p.GetStatus(),
+		body:   slices.Clone(buf.Bytes()),
+		header: http.Header{},
+	}
+
+	if hp, ok := p.(HeaderProvider); ok {
+		for key, values := range hp.Header() {
+			s.header[key] = slices.Clone(values)
+		}
+	}
+	if rt := p.getRetry(); !rt.isZero() {
+		s.header.Set("Retry-After", rt.header())
+	}
+
+	s.header.Set("Content-Type", contentType)
+	s.header.Set("X-Content-Type-Options", "nosniff")
+	s.header.Set("Content-Length", strconv.Itoa(len(s.body)))
+
+	for _, values := range s.header {
+		s.values += len(values)
+	}
+	// Content-Length of OPTIONS responses
+	s.values++
+
+	return s
+}
+
+func (s *staticHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Every value is copied into a single array, so the response does not share them
+	values := make([]string, 0, s.values)
+
+	// Returns a copy of v, with its capacity limited so appending to it does not overwrite
+	// other values
+	clone := func(v []string) []string {
+		start := len(values)
+		values = append(values, v...)
+		return values[start:len(values):len(values)]
+	}
+
+	h := w.Header()
+	for key, v := range s.header {
+		h[key] = clone(v)
+	}
+
+	if r.Method == http.MethodOptions {
+		// See problemHTTPWrapper.ServeHTTP
+		delete(h, "Content-Type")
+		delete(h, "X-Content-Type-Options")
+		h["Content-Length"] = clone(zeroContentLength)
+		w.WriteHeader(s.status)
+		return
+	}
+
+	w.WriteHeader(s.status)
+
+	if r.Method != http.MethodHead {
+		_, _ = w.Write(s.body)
+	}
+}
+
+var zeroContentLength = []string{"0"}
@@ -0,0 +1,447 @@
+package problem
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Violation is a deviation of a problem details document from RFC 9457, reported by
+// [ParseResponseStrict].
+type Violation struct {
+	// Name of the member the violation is about, empty if it is about the whole document.
+	Member string
+
+	// One of [ErrMemberType], [ErrMissingType], [ErrStatusMismatch], [ErrInvalidURI],
+	// [ErrXMLNamespace] and [ErrDuplicateMember].
+	Err error
+
+	// Details of the violation, like the expected and actual value types, may be empty.
+	Detail string
+}
+
+func (v Violation) Error() string {
+	msg := v.Err.Error()
+	if v.Member != "" {
+		msg = fmt.Sprintf("%q: %s", v.Member, msg)
+	}
+	if v.Detail != "" {
+		msg += " (" + v.Detail + ")"
+	}
+	return msg
+}
+
+// Unwrap returns v.Err, so errors.Is can be used to check the kind of violation.
+func (v Violation) Unwrap() error {
+	return v.Err
+}
+
+// ConformanceError is the error returned by [ParseResponseStrict] when the problem details
+// document does not conform to RFC 9457, listing every violation found.
+type ConformanceError struct {
+	Violations []Violation
+}
+
+func (e *ConformanceError) Error() string {
+	msgs := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		msgs[i] = v.Error()
+	}
+	return "problem details do not conform to RFC 9457: " + strings.Join(msgs, "; ")
+}
+
+// Unwrap returns the violations, so errors.Is(err, ErrMissingType) reports whether the type
+// member was missing, and so on.
+func (e *ConformanceError) Unwrap() []error {
+	errs := make([]error, len(e.Violations))
+	for i, v := range e.Violations {
+		errs[i] = v
+	}
+	return errs
+}
+
+// ParseResponseStrict is like [ParseResponseCustom], but also checks that the problem details
+// document conforms to RFC 9457, returning a *[ConformanceError] with every violation found:
+//
+//   - Members whose value has the wrong type, [ErrMemberType]. Extension members are checked
+//     against the fields of p, so custom structs and [Typed] problems get their extension
+//     members checked too.
+//   - A missing "type" member, [ErrMissingType].
+//   - A "status" member that does not match the status code of the response,
+//     [ErrStatusMismatch].
+//   - "type" and "instance" members that are not URI references, [ErrInvalidURI].
+//   - XML documents whose <problem> element is not in the 'urn:ietf:rfc:7807' namespace,
+//     [ErrXMLNamespace].
+//   - Duplicated members, [ErrDuplicateMember]. In JSON, the members of nested objects are
+//     checked too.
+//
+// p is populated as ParseResponseCustom does even when violations are found, unless the
+// document cannot be decoded at all, in which case the returned error joins the
+// *ConformanceError with the decoding error. Use errors.As to retrieve the report:
+//
+//	err := problem.ParseResponseStrict(res, &p)
+//
+//	var ce *problem.ConformanceError
+//	if errors.As(err, &ce) {
+//	    for _, v := range ce.Violations {
+//	        t.Error(v)
+//	    }
+//	}
+//
+// It is intended for contract tests against other APIs, in production ParseResponseCustom is
+// preferred, since it ignores the deviations as mandated by RFC 9457.
+func ParseResponseStrict(res *http.Response, p Problem) error {
+	return parseResponse(res, p, true)
+}
+
+// checkConformance returns the violations of RFC 9457 in the document b, in the format of
+// contentType, served with the given status code. Syntax errors are not reported, since they
+// are reported by the decoder.
+func checkConformance(b []byte, contentType string, status int, p Problem) []Violation {
+	switch contentType {
+	case MediaTypeProblemJSON:
+		return checkJSONConformance(b, status, p)
+	case MediaTypeProblemXML:
+		return checkXMLConformance(b, status, p)
+	}
+	return nil
+}
+
+func checkJSONConformance(b []byte, status int, p Problem) []Violation {
+	members, err := objectMembers(b)
+	if err != nil {
+		return nil
+	}
+
+	var violations []Violation
+
+	target := strictTarget(p)
+	seen := make(map[string]bool, len(members))
+
+	for _, m := range members {
+		if seen[m.name] {
+			violations = append(violations, Violation{Member: m.name, Err: ErrDuplicateMember})
+		}
+		seen[m.name] = true
+
+		pointers, _ := duplicateMembers(json.NewDecoder(bytes.NewReader(m.value)), "/"+escapePointer(m.name))
+		for _, pointer := range pointers {
+			violations = append(violations, Violation{
+				Member: m.name,
+				Err:    ErrDuplicateMember,
+				Detail: "at " + pointer,
+			})
+		}
+
+		if isRegisteredMember(m.name) {
+			expected := "string"
+			if m.name == "status" {
+				expected = "number"
+			}
+			if kind := jsonKind(m.value); kind != expected {
+				violations = append(violations, Violation{
+					Member: m.name,
+					Err:    ErrMemberType,
+					Detail: fmt.Sprintf("got JSON %s, expected %s", kind, expected),
+				})
+				continue
+			}
+			violations = append(violations, checkRegisteredValue(m.name, m.text(), status)...)
+			continue
+		}
+
+		if target == nil {
+			continue
+		}
+
+		name, _ := json.Marshal(m.name)
+		doc := append(append(append(append([]byte("{"), name...), ':'), m.value...), '}')
+
+		var typeErr *json.UnmarshalTypeError
+		if err := json.Unmarshal(doc, target); errors.As(err, &typeErr) {
+			violations = append(violations, Violation{
+				Member: m.name,
+				Err:    ErrMemberType,
+				Detail: fmt.Sprintf("got JSON %s, expected %s", typeErr.Value, typeErr.Type),
+			})
+		}
+	}
+
+	if !seen["type"] {
+		violations = append(violations, Violation{Member: "type", Err: ErrMissingType})
+	}
+
+	return violations
+}
+
+func checkXMLConformance(b []byte, status int, p Problem) []Violation {
+	d := xml.NewDecoder(bytes.NewReader(b))
+
+	var root xml.StartElement
+	for root.Name.Local == "" {
+		tok, err := d.Token()
+		if err != nil {
+			return nil
+		}
+		if start, ok := tok.(xml.StartElement); ok {
+			root = start
+		}
+	}
+
+	var violations []Violation
+
+	if root.Name.Space != xmlNamespace {
+		violations = append(violations, Violation{
+			Err:    ErrXMLNamespace,
+			Detail: fmt.Sprintf("got namespace %q", root.Name.Space),
+		})
+	}
+
+	target := strictTarget(p)
+	seen := map[string]bool{}
+
+	for {
+		offset := d.InputOffset()
+
+		tok, err := d.Token()
+		if err != nil {
+			break
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		name := start.Name.Local
+
+		text, hasChildren, err := elementText(d)
+		if err != nil {
+			break
+		}
+
+		if seen[name] {
+			violations = append(violations, Violation{Member: name, Err: ErrDuplicateMember})
+		}
+		seen[name] = true
+
+		if isRegisteredMember(name) {
+			if hasChildren {
+				violations = append(violations, Violation{
+					Member: name,
+					Err:    ErrMemberType,
+					Detail: "got element with child elements, expected text",
+				})
+				continue
+			}
+			violations = append(violations, checkRegisteredValue(name, text, status)...)
+			continue
+		}
+
+		if target == nil {
+			continue
+		}
+
+		doc := `<problem xmlns="` + xmlNamespace + `">` + string(b[offset:d.InputOffset()]) + `</problem>`
+		if err := xml.Unmarshal([]byte(doc), target); err != nil {
+			violations = append(violations, Violation{
+				Member: name,
+				Err:    ErrMemberType,
+				Detail: err.Error(),
+			})
+		}
+	}
+
+	if !seen["type"] {
+		violations = append(violations, Violation{Member: "type", Err: ErrMissingType})
+	}
+
+	return violations
+}
+
+// checkRegisteredValue checks the value of the registered member name, as text.
+func checkRegisteredValue(name, value string, status int) []Violation {
+	switch name {
+	case "status":
+		n, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil {
+			return []Violation{{
+				Member: name,
+				Err:    ErrMemberType,
+				Detail: fmt.Sprintf("got %q, expected an integer", value),
+			}}
+		}
+		if n != status {
+			return []Violation{{
+				Member: name,
+				Err:    ErrStatusMismatch,
+				Detail: fmt.Sprintf("got %d, the response has %d", n, status),
+			}}
+		}
+
+	case "type", "instance":
+		if !isURIReference(value) {
+			return []Violation{{
+				Member: name,
+				Err:    ErrInvalidURI,
+				Detail: fmt.Sprintf("got %q", value),
+			}}
+		}
+	}
+
+	return nil
+}
+
+// strictTarget returns a new value to check the types of the extension members of p against,
+// or nil if p is not a pointer.
+func strictTarget(p Problem) any {
+	if t, ok := p.(interface{ newExtensions() any }); ok {
+		return t.newExtensions()
+	}
+
+	rt := reflect.TypeOf(p)
+	if rt.Kind() != reflect.Pointer {
+		return nil
+	}
+	return reflect.New(rt.Elem()).Interface()
+}
+
+// duplicateMembers returns the JSON Pointers of the duplicated members of the objects in the
+// JSON value read from dec, whose JSON Pointer is pointer.
+func duplicateMembers(dec *json.Decoder, pointer string) ([]string, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	var duplicates []string
+
+	switch tok {
+	case json.Delim('{'):
+		seen := map[string]bool{}
+		for dec.More() {
+			tok, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			name, _ := tok.(string)
+
+			p := pointer + "/" + escapePointer(name)
+			if seen[name] {
+				duplicates = append(duplicates, p)
+			}
+			seen[name] = true
+
+			d, err := duplicateMembers(dec, p)
+			if err != nil {
+				return nil, err
+			}
+			duplicates = append(duplicates, d...)
+		}
+		_, err = dec.Token()
+
+	case json.Delim('['):
+		for i := 0; dec.More(); i++ {
+			d, err := duplicateMembers(dec, pointer+"/"+strconv.Itoa(i))
+			if err != nil {
+				return nil, err
+			}
+			duplicates = append(duplicates, d...)
+		}
+		_, err = dec.Token()
+	}
+
+	return duplicates, err
+}
+
+var pointerEscaper = strings.NewReplacer("~", "~0", "/", "~1")
+
+// escapePointer escapes name to be used as a JSON Pointer reference token (RFC 6901).
+func escapePointer(name string) string {
+	return pointerEscaper.Replace(name)
+}
+
+// jsonKind returns the kind of the JSON value b, as named by [json.UnmarshalTypeError].
+func jsonKind(b []byte) string {
+	if len(b) == 0 {
+		return ""
+	}
+	switch b[0] {
+	case '"':
+		return "string"
+	case '{':
+		return "object"
+	case '[':
+		return "array"
+	case 't', 'f':
+		return "bool"
+	case 'n':
+		return "null"
+	}
+	return "number"
+}
+
+// elementText reads the rest of the element started by the last token read from d, returning
+// its text and whether it has child elements.
+func elementText(d *xml.Decoder) (string, bool, error) {
+	var (
+		text        []byte
+		hasChildren bool
+	)
+
+	for depth := 1; depth > 0; {
+		tok, err := d.Token()
+		if err != nil {
+			return "", false, err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			depth++
+			hasChildren = true
+		case xml.EndElement:
+			depth--
+		case xml.CharData:
+			if depth == 1 {
+				text = append(text, t...)
+			}
+		}
+	}
+
+	return string(text), hasChildren, nil
+}
+
+// isURIReference reports whether s is a URI reference, as defined by RFC 3986, either absolute
+// or relative.
+func isURIReference(s string) bool {
+	if _, err := url.Parse(s); err != nil {
+		return false
+	}
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		case c == '%':
+			if i+2 >= len(s) || !isHex(s[i+1]) || !isHex(s[i+2]) {
+				return false
+			}
+			i += 2
+		case strings.IndexByte("-._~:/?#[]@!$&'()*+,;=", c) >= 0:
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
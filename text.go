@@ -0,0 +1,77 @@
+package problem
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const mediaTypeText = "text/plain"
+
+// Format returns a single-line, human-readable representation of p, suitable for logs and
+// command-line tools:
+//
+//	403 You do not have enough credit.: Your current balance is 30, but that costs 50. (https://example.com/probs/out-of-credit, /account/12345/msgs/abc) balance=30 currency=EUR
+//
+// That is, the status code, title and detail, then the type and instance between parentheses,
+// and finally the extension members sorted by name. Empty members are omitted. String values
+// of extension members are quoted when needed, other values are printed as JSON.
+//
+// It is also the representation used when the negotiated media type is 'text/plain'.
+//
+// [MapProblem] prints this representation for the %+v verb. The struct problems do not
+// implement [fmt.Formatter], since the method would be promoted to custom structs embedding
+// them, which would then be printed without their extension members; use Format instead.
+func Format(p Problem) string {
+	var sb strings.Builder
+
+	sb.WriteString(problemMessage(p.GetStatus(), p.GetTitle(), p.GetDetail()))
+
+	if typ, instance := p.GetType(), p.GetInstance(); typ != "" || instance != "" {
+		sb.WriteString(" (")
+		sb.WriteString(typ)
+		if typ != "" && instance != "" {
+			sb.WriteString(", ")
+		}
+		sb.WriteString(instance)
+		sb.WriteString(")")
+	}
+
+	extensions := extensionMembers(p)
+	slices.SortStableFunc(extensions, func(a, b member) int {
+		return cmp.Compare(a.name, b.name)
+	})
+
+	for _, m := range extensions {
+		sb.WriteString(" ")
+		sb.WriteString(m.name)
+		sb.WriteString("=")
+
+		value := m.text()
+		if m.isString() && (value == "" || strings.ContainsAny(value, " =\"\t\n")) {
+			value = strconv.Quote(value)
+		}
+		sb.WriteString(value)
+	}
+
+	return sb.String()
+}
+
+// Format implements [fmt.Formatter]: the %+v verb prints the problem as the package level
+// [Format] function does, the %v and %s verbs print the error message, and %q prints it quoted.
+// Other verbs, and the # flag, format m as a plain map.
+func (m MapProblem) Format(f fmt.State, verb rune) {
+	switch {
+	case verb == 'v' && f.Flag('+'):
+		io.WriteString(f, Format(m))
+	case verb == 'v' && f.Flag('#'):
+		fmt.Fprintf(f, fmt.FormatString(f, verb), map[string]any(m))
+	case verb == 'v', verb == 's', verb == 'q':
+		fmt.Fprintf(f, fmt.FormatString(f, verb), m.Error())
+	default:
+		fmt.Fprintf(f, fmt.FormatString(f, verb), map[string]any(m))
+	}
+}
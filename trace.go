@@ -0,0 +1,124 @@
+package problem
+
+import (
+	"bytes"
+	"encoding/hex"
+	"net/http"
+	"strings"
+
+	tme_json "github.com/otaxhu/type-mismatch-encoding/encoding/json"
+	tme_xml "github.com/otaxhu/type-mismatch-encoding/encoding/xml"
+)
+
+// traceContext identifies the trace and span a problem occurred in, see
+// https://www.w3.org/TR/trace-context/
+type traceContext struct {
+	traceID string
+	spanID  string
+}
+
+func (t traceContext) isZero() bool {
+	return t.traceID == "" && t.spanID == ""
+}
+
+// members returns the "trace_id" and "span_id" extension members for t.
+func (t traceContext) members() []member {
+	var members []member
+	if t.traceID != "" {
+		members = append(members, member{name: "trace_id", value: []byte(`"` + t.traceID + `"`)})
+	}
+	if t.spanID != "" {
+		members = append(members, member{name: "span_id", value: []byte(`"` + t.spanID + `"`)})
+	}
+	return members
+}
+
+// parseTraceparent parses the value of a traceparent header, as defined in
+// https://www.w3.org/TR/trace-context/#traceparent-header
+func parseTraceparent(value string) (traceContext, bool) {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 {
+		return traceContext{}, false
+	}
+
+	version, traceID, spanID, flags := parts[0], parts[1], parts[2], parts[3]
+
+	// Version 00 has exactly 4 fields, future versions may add more
+	if !isLowerHex(version, 2) || version == "ff" || (version == "00" && len(parts) != 4) {
+		return traceContext{}, false
+	}
+	if !isLowerHex(traceID, 32) || traceID == strings.Repeat("0", 32) {
+		return traceContext{}, false
+	}
+	if !isLowerHex(spanID, 16) || spanID == strings.Repeat("0", 16) {
+		return traceContext{}, false
+	}
+	if !isLowerHex(flags, 2) {
+		return traceContext{}, false
+	}
+
+	return traceContext{traceID: traceID, spanID: spanID}, true
+}
+
+func isLowerHex(s string, n int) bool {
+	if len(s) != n || strings.ToLower(s) != s {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
+
+// requestTrace returns the trace context of r, taken from its traceparent header.
+func requestTrace(r *http.Request) (traceContext, bool) {
+	return parseTraceparent(r.Header.Get("traceparent"))
+}
+
+// decodeTrace returns the "trace_id" and "span_id" members of the problem document b. Members
+// with types other than string are ignored.
+func decodeTrace(b []byte, contentType string) traceContext {
+	switch contentType {
+	case MediaTypeProblemJSON:
+		v := struct {
+			TraceID any `json:"trace_id"`
+			SpanID  any `json:"span_id"`
+		}{}
+
+		dec := tme_json.NewDecoder(bytes.NewReader(b))
+		dec.AllowTypeMismatch()
+		if dec.Decode(&v) != nil {
+			return traceContext{}
+		}
+
+		traceID, _ := v.TraceID.(string)
+		spanID, _ := v.SpanID.(string)
+		return traceContext{traceID: traceID, spanID: spanID}
+
+	case MediaTypeProblemXML:
+		v := struct {
+			XMLName struct{} `xml:"urn:ietf:rfc:7807 problem"`
+			TraceID string   `xml:"trace_id"`
+			SpanID  string   `xml:"span_id"`
+		}{}
+
+		dec := tme_xml.NewDecoder(bytes.NewReader(b))
+		dec.AllowTypeMismatch = true
+		if dec.Decode(&v) != nil {
+			return traceContext{}
+		}
+
+		return traceContext{traceID: v.TraceID, spanID: v.SpanID}
+	}
+
+	return traceContext{}
+}
+
+// TraceContext returns the W3C Trace Context identifiers of the trace and span p occurred in,
+// taken from its "trace_id" and "span_id" extension members.
+//
+// They are added by the serving helpers when using [WithTraceContext], and are available on the
+// problems returned by [ParseResponse] and [ParseResponseCustom], even for XML responses parsed
+// into a [RegisteredProblem].
+func TraceContext(p Problem) (traceID, spanID string, ok bool) {
+	t := p.getTrace()
+	return t.traceID, t.spanID, !t.isZero()
+}
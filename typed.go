@@ -0,0 +1,198 @@
+package problem
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+
+	tme_json "github.com/otaxhu/type-mismatch-encoding/encoding/json"
+	tme_xml "github.com/otaxhu/type-mismatch-encoding/encoding/xml"
+)
+
+// Typed is a problem with the registered members and the extension members declared by the
+// struct E, without the need of writing a custom struct that embeds [RegisteredProblem]:
+//
+//	type OutOfCredit struct {
+//	    Balance  int      `json:"balance" xml:"balance"`
+//	    Accounts []string `json:"accounts" xml:"accounts>i"`
+//	}
+//
+//	p := problem.NewTyped(http.StatusForbidden, "Your current balance is 30", OutOfCredit{
+//	    Balance:  30,
+//	    Accounts: []string{"/account/12345", "/account/67890"},
+//	})
+//
+// The fields of E are flattened into the problem when marshaling, so the example above is
+// encoded in JSON as
+//
+//	{
+//	    "type": "about:blank",
+//	    "status": 403,
+//	    "title": "Forbidden",
+//	    "detail": "Your current balance is 30",
+//	    "instance": "",
+//	    "balance": 30,
+//	    "accounts": ["/account/12345", "/account/67890"]
+//	}
+//
+// and the same way in XML, the fields of E being children of the <problem> element. Members of E
+// named like registered members are ignored.
+//
+// *Typed implements [Problem], so it can be served by the serving helpers and filled by
+// [ParseResponseCustom], which fills both the registered members and E:
+//
+//	var p problem.Typed[OutOfCredit]
+//	err := problem.ParseResponseCustom(res, &p)
+//
+// E must be a struct, or a pointer to one, marshaled to a JSON object. A nil E has no extension
+// members.
+type Typed[E any] struct {
+	RegisteredProblem
+
+	Extensions E
+}
+
+// *Typed implements Problem
+var _ Problem = (*Typed[struct{}])(nil)
+
+// NewTyped returns a *[Typed] problem with the given extension members, and the registered
+// members set the same way as [NewRegistered].
+func NewTyped[E any](statusCode int, details string, extensions E) *Typed[E] {
+	return &Typed[E]{
+		RegisteredProblem: RegisteredProblem{
+			Type:   "about:blank",
+			Status: statusCode,
+			Title:  http.StatusText(statusCode),
+			Detail: details,
+			stack:  captureStack(1),
+		},
+		Extensions: extensions,
+	}
+}
+
+// MarshalJSON encodes t as a single JSON object, with the registered members followed by the
+// members of t.Extensions.
+func (t Typed[E]) MarshalJSON() ([]byte, error) {
+	registered, err := json.Marshal(t.RegisteredProblem)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := json.Marshal(t.Extensions)
+	if err != nil {
+		return nil, err
+	}
+
+	// A nil t.Extensions has no members
+	var extensions []member
+	if string(b) != "null" {
+		extensions, err = objectMembers(b)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	buf := bytes.NewBuffer(registered[:len(registered)-1])
+
+	for _, m := range extensions {
+		if isRegisteredMember(m.name) {
+			continue
+		}
+		name, _ := json.Marshal(m.name)
+		buf.WriteByte(',')
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(m.value)
+	}
+
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// UnmarshalJSON decodes the JSON object b into both the registered members and t.Extensions.
+// Members whose value type does not match the type of the field are ignored, as
+// [ParseResponseCustom] does.
+func (t *Typed[E]) UnmarshalJSON(b []byte) error {
+	for _, v := range []any{&t.RegisteredProblem, &t.Extensions} {
+		dec := tme_json.NewDecoder(bytes.NewReader(b))
+		dec.AllowTypeMismatch()
+
+		if err := dec.Decode(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MarshalXML encodes t as a single <problem> element, with the registered members followed by
+// the children of the element t.Extensions is marshaled to, if any.
+func (t Typed[E]) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	registered, err := xml.Marshal(t.RegisteredProblem)
+	if err != nil {
+		return err
+	}
+
+	// Anonymous types have no name to marshal them into, so the element is named explicitly
+	var extensions bytes.Buffer
+	ee := xml.NewEncoder(&extensions)
+	if err := ee.EncodeElement(t.Extensions, xml.StartElement{Name: xml.Name{Local: "extensions"}}); err != nil {
+		return err
+	}
+	if err := ee.Flush(); err != nil {
+		return err
+	}
+
+	c := xmlCopy{
+		root: true,
+		beforeEnd: func() error {
+			return xmlCopy{skip: isRegisteredMember}.copyBytes(e, extensions.Bytes())
+		},
+	}
+
+	return c.copyBytes(e, registered)
+}
+
+// UnmarshalXML decodes the <problem> element into both the registered members and
+// t.Extensions. Elements whose content does not match the type of the field are ignored, as
+// [ParseResponseCustom] does.
+func (t *Typed[E]) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	var buf bytes.Buffer
+
+	// Yields start, which was already consumed from d, and then the rest of the element
+	next := func() (xml.Token, error) {
+		if tok := xml.Token(start); start.Name.Local != "" {
+			start = xml.StartElement{}
+			return tok, nil
+		}
+		return d.Token()
+	}
+
+	e := xml.NewEncoder(&buf)
+	if err := (xmlCopy{root: true}).copy(e, next); err != nil {
+		return err
+	}
+	if err := e.Flush(); err != nil {
+		return err
+	}
+
+	return t.unmarshalProblemXML(buf.Bytes())
+}
+
+func (t *Typed[E]) unmarshalProblemXML(b []byte) error {
+	for _, v := range []any{&t.RegisteredProblem, &t.Extensions} {
+		dec := tme_xml.NewDecoder(bytes.NewReader(b))
+		dec.AllowTypeMismatch = true
+
+		if err := dec.Decode(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newExtensions returns a pointer to a new E, the extension members are checked against by
+// [ParseResponseStrict].
+func (t *Typed[E]) newExtensions() any {
+	return new(E)
+}
@@ -0,0 +1,77 @@
+package problem
+
+// FieldError describes a single validation error of a [ValidationProblem]. Exactly one of
+// Pointer, Parameter and Header identifies the invalid part of the request.
+type FieldError struct {
+	// Human-readable explanation of the error.
+	Detail string `json:"detail" xml:"detail"`
+
+	// JSON Pointer (RFC 6901) to the invalid member of the request body, e.g. "#/age".
+	Pointer string `json:"pointer,omitempty" xml:"pointer,omitempty"`
+
+	// Name of the invalid query parameter.
+	Parameter string `json:"parameter,omitempty" xml:"parameter,omitempty"`
+
+	// Name of the invalid request header.
+	Header string `json:"header,omitempty" xml:"header,omitempty"`
+}
+
+// ValidationProblem is a problem reporting one or more validation errors of a request, in an
+// "errors" extension member, as shown in the example of
+// https://www.rfc-editor.org/rfc/rfc9457.html#section-3
+//
+//	{
+//	    "type": "about:blank",
+//	    "status": 422,
+//	    "title": "Unprocessable Entity",
+//	    "detail": "Your request is not valid.",
+//	    "instance": "",
+//	    "errors": [
+//	        {
+//	            "detail": "must be a positive integer",
+//	            "pointer": "#/age"
+//	        }
+//	    ]
+//	}
+//
+// In XML, "errors" is encoded as an array following
+// https://www.rfc-editor.org/rfc/rfc9457.html#name-xml-format, every error being an <i> element.
+type ValidationProblem struct {
+	RegisteredProblem
+
+	Errors []FieldError `json:"errors" xml:"errors>i"`
+}
+
+// NewValidation returns a *[ValidationProblem] without errors, add them with
+// [ValidationProblem.AddPointer], [ValidationProblem.AddParameter] and
+// [ValidationProblem.AddHeader].
+func NewValidation(statusCode int, details string) *ValidationProblem {
+	return &ValidationProblem{
+		RegisteredProblem: *newRegistered(statusCode, details, 2),
+		Errors:            []FieldError{},
+	}
+}
+
+// AddPointer adds an error about the member of the request body referenced by the JSON Pointer
+// pointer, and returns v.
+func (v *ValidationProblem) AddPointer(pointer, detail string) *ValidationProblem {
+	v.Errors = append(v.Errors, FieldError{Detail: detail, Pointer: pointer})
+	return v
+}
+
+// AddParameter adds an error about the query parameter with the given name, and returns v.
+func (v *ValidationProblem) AddParameter(name, detail string) *ValidationProblem {
+	v.Errors = append(v.Errors, FieldError{Detail: detail, Parameter: name})
+	return v
+}
+
+// AddHeader adds an error about the request header with the given name, and returns v.
+func (v *ValidationProblem) AddHeader(name, detail string) *ValidationProblem {
+	v.Errors = append(v.Errors, FieldError{Detail: detail, Header: name})
+	return v
+}
+
+// HasErrors reports whether any error was added to v.
+func (v *ValidationProblem) HasErrors() bool {
+	return len(v.Errors) > 0
+}
@@ -0,0 +1,108 @@
+package problem
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"slices"
+)
+
+// problemView wraps a problem being served, changing the extension members it is marshaled with,
+// without modifying the problem itself.
+//
+// It implements [json.Marshaler] and [xml.Marshaler], so it works with every encoder used by the
+// serving helpers.
+type problemView struct {
+	Problem
+
+	// Reports whether the extension member with the given name must be omitted. Registered
+	// members are never omitted.
+	omit func(name string) bool
+
+	// Extension members added to the problem, replacing members with the same name. In XML they
+	// are encoded following RFC 9457 Appendix B.
+	extra []member
+}
+
+// unwrap returns the wrapped problem if the view does not change it.
+func (v *problemView) unwrap() Problem {
+	if v.omit == nil && len(v.extra) == 0 {
+		return v.Problem
+	}
+	return v
+}
+
+func (v *problemView) keep(name string) bool {
+	return v.omit == nil || isRegisteredMember(name) || !v.omit(name)
+}
+
+// replaced reports whether the member with the given name of the wrapped problem is replaced by
+// an extra member.
+func (v *problemView) replaced(name string) bool {
+	return slices.ContainsFunc(v.extra, func(m member) bool {
+		return m.name == name
+	})
+}
+
+func (v *problemView) MarshalJSON() ([]byte, error) {
+	members, err := problemMembers(v.Problem)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+
+	write := func(m member) {
+		if buf.Len() > 1 {
+			buf.WriteByte(',')
+		}
+		name, _ := json.Marshal(m.name)
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(m.value)
+	}
+
+	for _, m := range members {
+		if v.keep(m.name) && !v.replaced(m.name) {
+			write(m)
+		}
+	}
+	for _, m := range v.extra {
+		if v.keep(m.name) {
+			write(m)
+		}
+	}
+
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// MarshalXML re-encodes the tokens of the XML document of the wrapped problem, skipping the
+// omitted and replaced extension members, and adding the extra members at the end.
+func (v *problemView) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	b, err := xml.Marshal(v.Problem)
+	if err != nil {
+		return err
+	}
+
+	c := xmlCopy{
+		root: true,
+		skip: func(name string) bool {
+			return !v.keep(name) || v.replaced(name)
+		},
+		beforeEnd: func() error {
+			for _, m := range v.extra {
+				if !v.keep(m.name) {
+					continue
+				}
+				if err := encodeAppendixB(e, m.name, m.value); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+
+	return c.copyBytes(e, b)
+}
@@ -0,0 +1,170 @@
+package problem
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+)
+
+// xmlCopy re-encodes the tokens of an XML element into an [xml.Encoder].
+//
+// Namespace declarations are dropped, and children in the same namespace as the root element
+// are encoded without namespace, so they inherit the namespace declared by the encoder for the
+// root element instead of getting redundant declarations.
+type xmlCopy struct {
+	// Reports whether the child of the root element with the given name must be omitted, may be
+	// nil.
+	skip func(name string) bool
+
+	// Whether the root element itself is encoded, or only its children.
+	root bool
+
+	// Called before encoding the end of the root element, may be nil.
+	beforeEnd func() error
+}
+
+// copyBytes copies the root element of the XML document b.
+func (c xmlCopy) copyBytes(e *xml.Encoder, b []byte) error {
+	return c.copy(e, xml.NewDecoder(bytes.NewReader(b)).Token)
+}
+
+// copy copies the element whose tokens are returned by next, until its end.
+func (c xmlCopy) copy(e *xml.Encoder, next func() (xml.Token, error)) error {
+	// Namespace of the root element
+	var rootSpace string
+
+	depth, skipDepth := 0, 0
+
+	for {
+		tok, err := next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			depth++
+			if skipDepth > 0 {
+				continue
+			}
+			if depth == 2 && c.skip != nil && c.skip(t.Name.Local) {
+				skipDepth = depth
+				continue
+			}
+			if depth == 1 {
+				rootSpace = t.Name.Space
+				if !c.root {
+					continue
+				}
+			} else if t.Name.Space == rootSpace {
+				t.Name.Space = ""
+			}
+			tok = xml.StartElement{Name: t.Name, Attr: withoutXMLNS(t.Attr)}
+
+		case xml.EndElement:
+			depth--
+			if skipDepth > 0 {
+				if depth < skipDepth {
+					skipDepth = 0
+				}
+				continue
+			}
+			if depth == 0 {
+				if c.beforeEnd != nil {
+					if err := c.beforeEnd(); err != nil {
+						return err
+					}
+				}
+				if c.root {
+					return e.EncodeToken(t)
+				}
+				return nil
+			}
+			if t.Name.Space == rootSpace {
+				t.Name.Space = ""
+			}
+			tok = t
+
+		default:
+			if skipDepth > 0 || depth == 0 {
+				continue
+			}
+		}
+
+		if err := e.EncodeToken(xml.CopyToken(tok)); err != nil {
+			return err
+		}
+	}
+}
+
+// xmlChildren returns the root element of the XML document b, and its child elements, with
+// namespaces normalized as [xmlCopy] does.
+func xmlChildren(b []byte) (xml.StartElement, []xmlChild, error) {
+	var (
+		root     xml.StartElement
+		children []xmlChild
+	)
+
+	d := xml.NewDecoder(bytes.NewReader(b))
+	depth := 0
+
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			return root, children, nil
+		} else if err != nil {
+			return root, nil, err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			depth++
+			if depth == 1 {
+				root = xml.StartElement{Name: t.Name, Attr: withoutXMLNS(t.Attr)}
+				continue
+			}
+			if t.Name.Space == root.Name.Space {
+				t.Name.Space = ""
+			}
+			if depth == 2 {
+				children = append(children, xmlChild{name: t.Name.Local})
+			}
+			tok = xml.StartElement{Name: t.Name, Attr: withoutXMLNS(t.Attr)}
+
+		case xml.EndElement:
+			depth--
+			if depth == 0 {
+				continue
+			}
+			if t.Name.Space == root.Name.Space {
+				t.Name.Space = ""
+			}
+			tok = t
+
+		default:
+			if depth < 2 {
+				continue
+			}
+		}
+
+		if depth >= 1 && len(children) > 0 {
+			c := &children[len(children)-1]
+			c.tokens = append(c.tokens, xml.CopyToken(tok))
+		}
+	}
+}
+
+// withoutXMLNS returns attrs without namespace declarations, the encoder declares the namespaces
+// of the elements by itself.
+func withoutXMLNS(attrs []xml.Attr) []xml.Attr {
+	var out []xml.Attr
+	for _, a := range attrs {
+		if a.Name.Local == "xmlns" || a.Name.Space == "xmlns" {
+			continue
+		}
+		out = append(out, a)
+	}
+	return out
+}